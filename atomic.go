@@ -0,0 +1,124 @@
+package snowflake
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// A Generator creates unique snowflake IDs.
+type Generator interface {
+	Generate() ID
+}
+
+var (
+	_ Generator = (*Node)(nil)
+	_ Generator = (*AtomicNode)(nil)
+)
+
+// An AtomicNode generates the same IDs as a Node, but instead of a mutex it
+// keeps the last timestamp and step number together in a single word that is
+// updated with an atomic compare-and-swap.  Under heavy parallel load this
+// avoids goroutines queueing on the node's lock.
+//
+// An AtomicNode always waits when its step number is exhausted or its clock
+// moves backwards, like Node.Generate does.
+type AtomicNode struct {
+	// state holds the last timestamp above the last step number.  It is
+	// first in the struct so it is 64-bit aligned on 32-bit platforms.
+	state uint64
+
+	layout Layout
+	clock  Clock
+	epoch  time.Time
+	unit   time.Duration
+	node   int64
+
+	stepBits  uint8
+	stepMask  uint64
+	timeShift uint8
+	nodeShift uint8
+}
+
+// NewAtomicNode returns a new AtomicNode that generates IDs using the layout
+// and node number held in c.  Only the Layout, Node, Fields and Clock settings
+// of c are used, so step numbers always start at zero.  c.Store is not
+// supported, as an AtomicNode does not save a high-water mark.
+func NewAtomicNode(c Config) (*AtomicNode, error) {
+
+	if c.Store != nil {
+		return nil, errors.New("AtomicNode does not support Config.Store")
+	}
+
+	l, err := c.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := c.node()
+	if err != nil {
+		return nil, err
+	}
+
+	a := AtomicNode{}
+	a.layout = l
+	a.unit = l.TimeUnit
+	a.node = node
+
+	a.stepBits = l.StepBits
+	a.stepMask = uint64(l.MaxStep())
+	a.timeShift = l.NodeBits + l.StepBits
+	a.nodeShift = l.StepBits
+
+	a.clock = c.clock()
+	a.epoch = epoch(a.clock, l.Epoch)
+
+	return &a, nil
+}
+
+// Layout returns the Layout used by the node.
+func (a *AtomicNode) Layout() Layout {
+	l := a.layout
+	l.Fields = append([]Field(nil), a.layout.Fields...)
+	return l
+}
+
+// Decompose splits an ID into its time, node and step fields using the
+// node's Layout.
+func (a *AtomicNode) Decompose(id ID) Parts {
+	return a.layout.Decompose(id)
+}
+
+// Generate creates and returns a unique snowflake ID
+func (a *AtomicNode) Generate() ID {
+
+	for {
+		old := atomic.LoadUint64(&a.state)
+		last := int64(old >> a.stepBits)
+		step := old & a.stepMask
+
+		now := int64(a.clock.Now().Sub(a.epoch) / a.unit)
+
+		if now < last {
+			// the clock moved backwards, wait for it to catch up
+			continue
+		}
+
+		if now == last {
+			if step == a.stepMask {
+				// the step number is exhausted, wait for the next time unit
+				continue
+			}
+			step++
+		} else {
+			step = 0
+		}
+
+		if atomic.CompareAndSwapUint64(&a.state, old, uint64(now)<<a.stepBits|step) {
+			return ID(now<<a.timeShift |
+				(a.node << a.nodeShift) |
+				int64(step),
+			)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package snowflake
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestAtomicNodeMatchesNode(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 4,
+	}
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	nc := snowflaketest.NewClock(start)
+	nc.AutoAdvance(30 * time.Microsecond)
+	node, err := NewNodeWithConfig(Config{Layout: l, Node: 7, Clock: nc})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	ac := snowflaketest.NewClock(start)
+	ac.AutoAdvance(30 * time.Microsecond)
+	anode, err := NewAtomicNode(Config{Layout: l, Node: 7, Clock: ac})
+	if err != nil {
+		t.Fatalf("error creating NewAtomicNode, %s", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		want := node.Generate()
+		if got := anode.Generate(); got != want {
+			t.Fatalf("id %d at %d != %d", got, i, want)
+		}
+	}
+}
+
+func TestAtomicNodeParallel(t *testing.T) {
+
+	node, err := NewAtomicNode(Config{Layout: DefaultLayout(), Node: 1})
+	if err != nil {
+		t.Fatalf("error creating NewAtomicNode, %s", err)
+	}
+
+	const workers, count = 8, 10000
+
+	var wg sync.WaitGroup
+	results := make([][]ID, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ids := make([]ID, count)
+			for i := range ids {
+				ids[i] = node.Generate()
+			}
+			results[w] = ids
+		}(w)
+	}
+	wg.Wait()
+
+	seen := make(map[ID]bool, workers*count)
+	for _, ids := range results {
+		for i, id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = true
+			if i > 0 && id <= ids[i-1] {
+				t.Fatalf("id %d not greater than %d", id, ids[i-1])
+			}
+		}
+	}
+}
+
+func BenchmarkGenerateParallel(b *testing.B) {
+
+	// a large step number keeps the benchmark from being limited by the
+	// number of IDs allowed per millisecond
+	l := DefaultLayout()
+	l.NodeBits, l.StepBits = 1, 21
+
+	b.Run("Node", func(b *testing.B) {
+		node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1})
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = node.Generate()
+			}
+		})
+	})
+
+	b.Run("AtomicNode", func(b *testing.B) {
+		node, _ := NewAtomicNode(Config{Layout: l, Node: 1})
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = node.Generate()
+			}
+		})
+	})
+}
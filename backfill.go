@@ -0,0 +1,129 @@
+package snowflake
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoBackfill is returned by Node.GenerateAt when the node was created
+	// without a Backfill generator.
+	ErrNoBackfill = errors.New("node has no backfill generator")
+
+	// ErrBackfillConflict is returned when a Backfill generator does not use
+	// the same Layout as a Node, or uses the same node number.
+	ErrBackfillConflict = errors.New("backfill generator must use the node's layout and a different node number")
+
+	// ErrStepExhausted is returned by Backfill.GenerateAt when every step
+	// number in the requested time unit has been used.
+	ErrStepExhausted = errors.New("no step numbers left for time")
+)
+
+// A Backfill generates IDs for given times instead of the current time, for
+// example to give legacy records IDs that hold their original creation time.
+// It keeps a step number for each time unit it has been asked for, so it
+// needs no clock and times can be requested in any order.
+//
+// A Backfill has no way of knowing which IDs live Nodes have generated, so it
+// must be given a node number that no live Node uses, or IDs may collide.
+// Memory use grows with the number of distinct time units requested.
+type Backfill struct {
+	mu     sync.Mutex
+	layout Layout
+	node   int64
+	steps  map[int64]int64
+
+	stepMask  int64
+	timeShift uint8
+	nodeShift uint8
+}
+
+// NewBackfill returns a new Backfill that generates IDs using the layout and
+// node number held in c.  Only the Layout, Node and Fields settings of c are
+// used, and c.Store is not supported.
+func NewBackfill(c Config) (*Backfill, error) {
+
+	if c.Store != nil {
+		return nil, errors.New("Backfill does not support Config.Store")
+	}
+
+	l, err := c.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := c.node()
+	if err != nil {
+		return nil, err
+	}
+
+	b := Backfill{}
+	b.layout = l
+	b.node = node
+	b.steps = make(map[int64]int64)
+	b.stepMask = l.MaxStep()
+	b.timeShift = l.NodeBits + l.StepBits
+	b.nodeShift = l.StepBits
+
+	return &b, nil
+}
+
+// Layout returns the Layout used by the generator.
+func (b *Backfill) Layout() Layout {
+	l := b.layout
+	l.Fields = append([]Field(nil), b.layout.Fields...)
+	return l
+}
+
+// GenerateAt creates and returns a unique snowflake ID holding the time t.
+// It returns ErrTimeBeforeEpoch or ErrTimeOverflow if t does not fit in the
+// Layout, and ErrStepExhausted once every step number for the time unit of t
+// has been used.
+func (b *Backfill) GenerateAt(t time.Time) (ID, error) {
+
+	ticks := b.layout.ticks(t)
+	if ticks < 0 {
+		return 0, ErrTimeBeforeEpoch
+	}
+	if ticks > b.layout.maxTicks() {
+		return 0, ErrTimeOverflow
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	step := b.steps[ticks]
+	if step > b.stepMask {
+		return 0, ErrStepExhausted
+	}
+	b.steps[ticks] = step + 1
+
+	return ID(ticks<<b.timeShift |
+		(b.node << b.nodeShift) |
+		step,
+	), nil
+}
+
+// GenerateAt creates and returns a unique snowflake ID holding the time t
+// rather than the current time, using the Backfill generator set in the
+// node's Config.  It returns ErrNoBackfill if there is none, see
+// Backfill.GenerateAt for the other errors.
+func (n *Node) GenerateAt(t time.Time) (ID, error) {
+
+	if n.backfill == nil {
+		return 0, ErrNoBackfill
+	}
+
+	return n.backfill.GenerateAt(t)
+}
+
+// sameLayout reports whether a and b lay out IDs in the same way.
+func sameLayout(a, b Layout) bool {
+	return a.Epoch.Equal(b.Epoch) &&
+		a.unit() == b.unit() &&
+		a.TimeBits == b.TimeBits &&
+		a.NodeBits == b.NodeBits &&
+		a.StepBits == b.StepBits &&
+		a.Unsigned == b.Unsigned
+}
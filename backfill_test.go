@@ -0,0 +1,69 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackfill(t *testing.T) {
+
+	l := DefaultLayout()
+	l.StepBits = 2
+	l.NodeBits = 10
+
+	b, err := NewBackfill(Config{Layout: l, Node: 1023})
+	if err != nil {
+		t.Fatalf("error creating NewBackfill, %s", err)
+	}
+
+	node, err := NewNodeWithConfig(Config{Layout: l, Node: 1, Backfill: b})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	then := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	seen := make(map[ID]bool)
+	for i := 0; i < 4; i++ {
+		id, err := node.GenerateAt(then)
+		if err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+
+		p := node.Decompose(id)
+		if !p.Time.Equal(then) || p.Node != 1023 || p.Step != int64(i) {
+			t.Fatalf("decomposed %+v", p)
+		}
+	}
+
+	if _, err := node.GenerateAt(then); err != ErrStepExhausted {
+		t.Fatalf("error %v != %v", err, ErrStepExhausted)
+	}
+
+	// other time units have sequences of their own
+	if _, err := node.GenerateAt(then.Add(-time.Millisecond)); err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+
+	if _, err := node.GenerateAt(l.Epoch.Add(-time.Millisecond)); err != ErrTimeBeforeEpoch {
+		t.Fatalf("error %v != %v", err, ErrTimeBeforeEpoch)
+	}
+	if _, err := node.GenerateAt(l.Epoch.Add(l.Lifetime())); err != ErrTimeOverflow {
+		t.Fatalf("error %v != %v", err, ErrTimeOverflow)
+	}
+
+	live, _ := NewNode(1)
+	if _, err := live.GenerateAt(then); err != ErrNoBackfill {
+		t.Fatalf("error %v != %v", err, ErrNoBackfill)
+	}
+
+	if _, err := NewNodeWithConfig(Config{Layout: l, Node: 1023, Backfill: b}); err != ErrBackfillConflict {
+		t.Fatalf("error %v != %v", err, ErrBackfillConflict)
+	}
+	if _, err := NewNodeWithConfig(Config{Layout: DefaultLayout(), Node: 1, Backfill: b}); err != ErrBackfillConflict {
+		t.Fatalf("error %v != %v", err, ErrBackfillConflict)
+	}
+}
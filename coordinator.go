@@ -0,0 +1,280 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ Coordinator = (*MemoryCoordinator)(nil)
+	_ Coordinator = (*FileCoordinator)(nil)
+)
+
+// DefaultLeaseGrace is how long a released or expired node number is held
+// back before it is leased again, when a Coordinator's Grace is not set.
+const DefaultLeaseGrace = time.Second
+
+// released is the token of a lease that has been given up.
+const released = "released"
+
+// A MemoryCoordinator is a Coordinator that keeps its leases in memory, so it
+// only coordinates generators within one process.  It is mostly useful in
+// tests.
+type MemoryCoordinator struct {
+	// Clock is used to set and check lease expiry.  If nil, SystemClock is
+	// used.
+	Clock Clock
+
+	// Grace is how long a released or expired node number is held back
+	// before it is leased again, so the new holder cannot generate IDs in
+	// the same time unit as the old one.  It must be at least one time unit
+	// plus the clock skew between generators.  If zero, DefaultLeaseGrace is
+	// used.
+	Grace time.Duration
+
+	mu     sync.Mutex
+	leases map[int64]Lease
+}
+
+// NewMemoryCoordinator returns a new MemoryCoordinator using clock, or
+// SystemClock if clock is nil.
+func NewMemoryCoordinator(clock Clock) *MemoryCoordinator {
+	return &MemoryCoordinator{Clock: clock}
+}
+
+// Acquire implements Coordinator.
+func (m *MemoryCoordinator) Acquire(ctx context.Context, maxNode int64, ttl time.Duration) (Lease, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.leases == nil {
+		m.leases = make(map[int64]Lease)
+	}
+
+	now := clockOrSystem(m.Clock).Now()
+	for node := int64(0); node <= maxNode; node++ {
+		if l, ok := m.leases[node]; ok && now.Before(l.Expires.Add(grace(m.Grace))) {
+			continue
+		}
+
+		token, err := newToken()
+		if err != nil {
+			return Lease{}, err
+		}
+
+		l := Lease{Node: node, Token: token, Expires: now.Add(ttl)}
+		m.leases[node] = l
+		return l, nil
+	}
+
+	return Lease{}, ErrNoFreeNode
+}
+
+// Renew implements Coordinator.
+func (m *MemoryCoordinator) Renew(ctx context.Context, l Lease, ttl time.Duration) (Lease, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clockOrSystem(m.Clock).Now()
+	if cur, ok := m.leases[l.Node]; !ok || cur.Token != l.Token || !now.Before(cur.Expires) {
+		return Lease{}, ErrLeaseLost
+	}
+
+	l.Expires = now.Add(ttl)
+	m.leases[l.Node] = l
+	return l, nil
+}
+
+// Release implements Coordinator.
+func (m *MemoryCoordinator) Release(ctx context.Context, l Lease) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cur, ok := m.leases[l.Node]; ok && cur.Token == l.Token {
+		m.leases[l.Node] = releasedLease(cur, clockOrSystem(m.Clock).Now())
+	}
+
+	return nil
+}
+
+// A FileCoordinator is a Coordinator that keeps each lease in a file in Dir,
+// so it coordinates generators on one machine.  Changes are guarded by an
+// flock on a lock file in Dir, which the operating system releases if the
+// process holding it dies.  On platforms without flock the lock file is
+// created exclusively instead, and one left behind by a crashed process has
+// to be removed by hand.
+type FileCoordinator struct {
+	Dir string
+
+	// Clock is used to set and check lease expiry.  If nil, SystemClock is
+	// used.
+	Clock Clock
+
+	// Grace is how long a released or expired node number is held back, see
+	// MemoryCoordinator.Grace.
+	Grace time.Duration
+}
+
+// NewFileCoordinator returns a new FileCoordinator keeping its leases in dir.
+func NewFileCoordinator(dir string) *FileCoordinator {
+	return &FileCoordinator{Dir: dir}
+}
+
+// Acquire implements Coordinator.
+func (f *FileCoordinator) Acquire(ctx context.Context, maxNode int64, ttl time.Duration) (Lease, error) {
+
+	unlock, err := f.lock(ctx)
+	if err != nil {
+		return Lease{}, err
+	}
+	defer unlock()
+
+	now := clockOrSystem(f.Clock).Now()
+	for node := int64(0); node <= maxNode; node++ {
+		cur, err := f.read(node)
+		if err != nil {
+			return Lease{}, err
+		}
+		if now.Before(cur.Expires.Add(grace(f.Grace))) {
+			continue
+		}
+
+		token, err := newToken()
+		if err != nil {
+			return Lease{}, err
+		}
+
+		l := Lease{Node: node, Token: token, Expires: now.Add(ttl)}
+		return l, f.write(l)
+	}
+
+	return Lease{}, ErrNoFreeNode
+}
+
+// Renew implements Coordinator.
+func (f *FileCoordinator) Renew(ctx context.Context, l Lease, ttl time.Duration) (Lease, error) {
+
+	unlock, err := f.lock(ctx)
+	if err != nil {
+		return Lease{}, err
+	}
+	defer unlock()
+
+	cur, err := f.read(l.Node)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	now := clockOrSystem(f.Clock).Now()
+	if cur.Token != l.Token || !now.Before(cur.Expires) {
+		return Lease{}, ErrLeaseLost
+	}
+
+	l.Expires = now.Add(ttl)
+	return l, f.write(l)
+}
+
+// Release implements Coordinator.
+func (f *FileCoordinator) Release(ctx context.Context, l Lease) error {
+
+	unlock, err := f.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cur, err := f.read(l.Node)
+	if err != nil {
+		return err
+	}
+
+	if cur.Token != l.Token {
+		return nil
+	}
+
+	return f.write(releasedLease(cur, clockOrSystem(f.Clock).Now()))
+}
+
+func (f *FileCoordinator) path(node int64) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("node-%d.lease", node))
+}
+
+// read returns the lease held in the file for node, or an empty Lease if
+// there is none.
+func (f *FileCoordinator) read(node int64) (Lease, error) {
+
+	b, err := ioutil.ReadFile(f.path(node))
+	if os.IsNotExist(err) {
+		return Lease{Node: node}, nil
+	}
+	if err != nil {
+		return Lease{}, err
+	}
+
+	l := Lease{Node: node}
+	lines := strings.Fields(string(b))
+	if len(lines) != 2 {
+		return Lease{}, fmt.Errorf("malformed lease file %s", f.path(node))
+	}
+	l.Token = lines[0]
+	if err := l.Expires.UnmarshalText([]byte(lines[1])); err != nil {
+		return Lease{}, err
+	}
+
+	return l, nil
+}
+
+func (f *FileCoordinator) write(l Lease) error {
+
+	b, err := l.Expires.UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+
+	return writeFile(f.path(l.Node), []byte(l.Token+"\n"+string(b)+"\n"))
+}
+
+// lock takes the FileCoordinator's lock, see lockFile.
+func (f *FileCoordinator) lock(ctx context.Context) (func(), error) {
+	return lockFile(ctx, filepath.Join(f.Dir, "lock"))
+}
+
+// newToken returns a random token identifying a lease holder.
+func newToken() (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// releasedLease returns l given up at now, so that its node number is held
+// back for the grace period from now rather than from its expiry.
+func releasedLease(l Lease, now time.Time) Lease {
+	l.Token = released
+	if now.Before(l.Expires) {
+		l.Expires = now
+	}
+	return l
+}
+
+// grace returns g, or DefaultLeaseGrace if g is zero.
+func grace(g time.Duration) time.Duration {
+	if g == 0 {
+		return DefaultLeaseGrace
+	}
+	return g
+}
@@ -0,0 +1,129 @@
+package snowflake
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func testCoordinator(t *testing.T, c Coordinator, clock *snowflaketest.Clock) {
+
+	ctx := context.Background()
+
+	a, err := c.Acquire(ctx, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("error acquiring, %s", err)
+	}
+	b, err := c.Acquire(ctx, 1, time.Second)
+	if err != nil {
+		t.Fatalf("error acquiring, %s", err)
+	}
+	if a.Node == b.Node {
+		t.Fatalf("node %d leased twice", a.Node)
+	}
+
+	if _, err := c.Acquire(ctx, 1, time.Second); err != ErrNoFreeNode {
+		t.Fatalf("error %v != %v", err, ErrNoFreeNode)
+	}
+
+	// a released node number is held back for the grace period
+	if err := c.Release(ctx, b); err != nil {
+		t.Fatalf("error releasing, %s", err)
+	}
+	if _, err := c.Acquire(ctx, 1, time.Second); err != ErrNoFreeNode {
+		t.Fatalf("error %v != %v", err, ErrNoFreeNode)
+	}
+	if _, err := c.Renew(ctx, b, time.Second); err != ErrLeaseLost {
+		t.Fatalf("error %v != %v", err, ErrLeaseLost)
+	}
+
+	clock.Advance(DefaultLeaseGrace)
+	if a, err = c.Renew(ctx, a, time.Minute); err != nil {
+		t.Fatalf("error renewing, %s", err)
+	}
+	if b, err = c.Acquire(ctx, 1, time.Second); err != nil {
+		t.Fatalf("error acquiring, %s", err)
+	}
+
+	// b expires, and is taken over once the grace period has passed
+	clock.Advance(time.Second)
+	if _, err := c.Renew(ctx, b, time.Second); err != ErrLeaseLost {
+		t.Fatalf("error %v != %v", err, ErrLeaseLost)
+	}
+	if _, err := c.Acquire(ctx, 1, time.Second); err != ErrNoFreeNode {
+		t.Fatalf("error %v != %v", err, ErrNoFreeNode)
+	}
+
+	clock.Advance(DefaultLeaseGrace)
+	taken, err := c.Acquire(ctx, 1, time.Second)
+	if err != nil {
+		t.Fatalf("error acquiring, %s", err)
+	}
+	if taken.Node != b.Node {
+		t.Fatalf("node %d != %d", taken.Node, b.Node)
+	}
+
+	// releasing a lost lease leaves the new holder alone
+	if err := c.Release(ctx, b); err != nil {
+		t.Fatalf("error releasing, %s", err)
+	}
+	if _, err := c.Renew(ctx, taken, time.Second); err != nil {
+		t.Fatalf("error renewing, %s", err)
+	}
+}
+
+func TestMemoryCoordinator(t *testing.T) {
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	testCoordinator(t, NewMemoryCoordinator(clock), clock)
+}
+
+func TestFileCoordinator(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "snowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	c := NewFileCoordinator(dir)
+	c.Clock = clock
+	testCoordinator(t, c, clock)
+}
+
+func TestFileCoordinatorConcurrent(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "snowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// each goroutine uses its own FileCoordinator, like separate processes
+	const n = 20
+	leases := make(chan Lease, n)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			l, err := NewFileCoordinator(dir).Acquire(context.Background(), n-1, time.Minute)
+			leases <- l
+			errs <- err
+		}()
+	}
+
+	seen := make(map[int64]bool)
+	for i := 0; i < n; i++ {
+		l := <-leases
+		if err := <-errs; err != nil {
+			t.Fatalf("error acquiring, %s", err)
+		}
+		if seen[l.Node] {
+			t.Fatalf("node %d leased twice", l.Node)
+		}
+		seen[l.Node] = true
+	}
+}
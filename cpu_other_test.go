@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package snowflake
+
+import "time"
+
+// cpuTime returns -1 as the CPU time used by the process is not available on
+// this platform.
+func cpuTime() time.Duration {
+	return -1
+}
@@ -0,0 +1,76 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultNodeVar is the environment variable NodeFromEnv reads the node
+// number from when NodeEnv.Var is not set.
+const DefaultNodeVar = "SNOWFLAKE_NODE"
+
+// ErrNoNodeEnv is returned by NodeFromEnv when neither its variable nor
+// HOSTNAME holds a node number.
+var ErrNoNodeEnv = errors.New("no node number in environment")
+
+// NodeEnv describes where NodeFromEnv finds the node number.
+type NodeEnv struct {
+	// Var is the environment variable holding the node number.  If empty,
+	// DefaultNodeVar is used.
+	Var string
+
+	// Offset is added to the node number, so that for example each cluster
+	// running the same StatefulSet can be given a range of its own.
+	Offset int64
+}
+
+// Node returns the node number from the environment variable e.Var, or if it
+// is not set, the ordinal at the end of HOSTNAME, as set for the pods of a
+// Kubernetes StatefulSet (svc-17 is 17).  e.Offset is added to the result.
+func (e NodeEnv) Node() (int64, error) {
+
+	name := e.Var
+	if name == "" {
+		name = DefaultNodeVar
+	}
+
+	if v := os.Getenv(name); v != "" {
+		node, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		return node + e.Offset, nil
+	}
+
+	host := os.Getenv("HOSTNAME")
+	i := strings.LastIndexByte(host, '-')
+	if i < 0 {
+		return 0, ErrNoNodeEnv
+	}
+
+	node, err := strconv.ParseInt(host[i+1:], 10, 64)
+	if err != nil {
+		return 0, ErrNoNodeEnv
+	}
+
+	return node + e.Offset, nil
+}
+
+// NodeFromEnv returns a new Node using the settings in c, with the node
+// number found as described by e, see NodeEnv.Node.  The Node and Fields
+// settings of c are replaced, and the node number must fit in c.Layout.
+func NodeFromEnv(c Config, e NodeEnv) (*Node, error) {
+
+	node, err := e.Node()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Node = node
+	c.Fields = nil
+
+	return NewNodeWithConfig(c)
+}
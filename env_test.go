@@ -0,0 +1,77 @@
+package snowflake
+
+import (
+	"os"
+	"testing"
+)
+
+// setenv sets or, if value is empty, unsets the environment variable key
+// until the test finishes.
+func setenv(t *testing.T, key, value string) {
+
+	old, ok := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+}
+
+func TestNodeEnv(t *testing.T) {
+
+	tests := []struct {
+		node, host string
+		e          NodeEnv
+		want       int64
+		err        bool
+	}{
+		{node: "12", host: "svc-3", want: 12},
+		{host: "svc-3", want: 3},
+		{host: "my-svc-17", e: NodeEnv{Offset: 100}, want: 117},
+		{node: "5", e: NodeEnv{Offset: 100}, want: 105},
+		{host: "svc", err: true},
+		{host: "svc-x", err: true},
+		{node: "five", err: true},
+	}
+
+	for _, tt := range tests {
+		setenv(t, DefaultNodeVar, tt.node)
+		setenv(t, "HOSTNAME", tt.host)
+
+		node, err := tt.e.Node()
+		if (err != nil) != tt.err || node != tt.want {
+			t.Fatalf("%+v: got %d, %v", tt, node, err)
+		}
+	}
+
+	setenv(t, "MY_NODE", "7")
+	if node, _ := (NodeEnv{Var: "MY_NODE"}).Node(); node != 7 {
+		t.Fatalf("node %d != 7", node)
+	}
+}
+
+func TestNodeFromEnv(t *testing.T) {
+
+	setenv(t, DefaultNodeVar, "")
+	setenv(t, "HOSTNAME", "svc-17")
+
+	node, err := NodeFromEnv(Config{Layout: DefaultLayout()}, NodeEnv{Offset: 1000})
+	if err != nil {
+		t.Fatalf("error creating NodeFromEnv, %s", err)
+	}
+	if p := node.Decompose(node.Generate()); p.Node != 1017 {
+		t.Fatalf("node %d != 1017", p.Node)
+	}
+
+	if _, err := NodeFromEnv(Config{Layout: DefaultLayout()}, NodeEnv{Offset: 1010}); err == nil {
+		t.Fatalf("no error for node 1027")
+	}
+}
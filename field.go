@@ -0,0 +1,185 @@
+package snowflake
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFieldBits is returned when the fields of a Layout do not add up to its
+// NodeBits.
+var ErrFieldBits = errors.New("layout fields must add up to the node bits")
+
+// ErrFieldName is returned when a Layout has a field with an empty or
+// repeated name.
+var ErrFieldName = errors.New("layout field names must be unique and not empty")
+
+// ErrUnknownField is returned when a value is given for a field the Layout
+// does not have.
+var ErrUnknownField = errors.New("unknown layout field")
+
+// ErrFieldOutOfRange is returned when a field value does not fit in the bits
+// of its field.
+var ErrFieldOutOfRange = errors.New("field value out of range for layout")
+
+// A Field is a named part of the node number of a Layout, such as a
+// datacenter, worker or shard number.
+type Field struct {
+	Name string
+	Bits uint8
+}
+
+// Max returns the largest value the Field can hold.
+func (f Field) Max() int64 {
+	return -1 ^ (-1 << f.Bits)
+}
+
+// A LayoutBuilder is used to build a Layout with named node fields, for
+// example
+//
+//	layout, err := snowflake.NewLayoutBuilder(epoch).
+//		Field("datacenter", 5).
+//		Field("worker", 5).
+//		StepBits(12).
+//		Build()
+//
+// Fields are placed in the order they are added, from the most significant
+// bits down, and together make up the node number.
+type LayoutBuilder struct {
+	layout   Layout
+	timeBits bool
+}
+
+// NewLayoutBuilder returns a LayoutBuilder for a Layout with the given epoch.
+func NewLayoutBuilder(epoch time.Time) *LayoutBuilder {
+	return &LayoutBuilder{layout: Layout{Epoch: epoch}}
+}
+
+// TimeUnit sets the resolution of the timestamp.
+func (b *LayoutBuilder) TimeUnit(d time.Duration) *LayoutBuilder {
+	b.layout.TimeUnit = d
+	return b
+}
+
+// TimeBits sets the number of bits used for the timestamp.  If it is not
+// called the timestamp is given every bit not used by the other fields.
+func (b *LayoutBuilder) TimeBits(bits uint8) *LayoutBuilder {
+	b.layout.TimeBits = bits
+	b.timeBits = true
+	return b
+}
+
+// Field adds a named node field with the given number of bits.
+func (b *LayoutBuilder) Field(name string, bits uint8) *LayoutBuilder {
+	b.layout.Fields = append(b.layout.Fields, Field{Name: name, Bits: bits})
+	b.layout.NodeBits += bits
+	return b
+}
+
+// StepBits sets the number of bits used for the step number.
+func (b *LayoutBuilder) StepBits(bits uint8) *LayoutBuilder {
+	b.layout.StepBits = bits
+	return b
+}
+
+// Unsigned makes the Layout use all 64 bits.
+func (b *LayoutBuilder) Unsigned() *LayoutBuilder {
+	b.layout.Unsigned = true
+	return b
+}
+
+// Build returns the Layout, or an error if it is not valid.
+func (b *LayoutBuilder) Build() (Layout, error) {
+
+	l := b.layout
+	l.Fields = append([]Field(nil), b.layout.Fields...)
+
+	if !b.timeBits {
+		used := int(l.NodeBits) + int(l.StepBits)
+		if used >= l.bits() {
+			return Layout{}, ErrNoTimeBits
+		}
+		l.TimeBits = uint8(l.bits() - used)
+		if l.TimeBits > 63 {
+			l.TimeBits = 63
+		}
+	}
+
+	if err := l.Validate(); err != nil {
+		return Layout{}, err
+	}
+
+	return l, nil
+}
+
+// NodeFromFields builds a node number from the values of the Layout's named
+// fields.  Fields that are not given are set to zero.
+func (l Layout) NodeFromFields(values map[string]int64) (int64, error) {
+
+	for name := range values {
+		if l.field(name) < 0 {
+			return 0, ErrUnknownField
+		}
+	}
+
+	var node int64
+	for _, f := range l.Fields {
+		v := values[f.Name]
+		if v < 0 || v > f.Max() {
+			return 0, ErrFieldOutOfRange
+		}
+		node = node<<f.Bits | v
+	}
+
+	return node, nil
+}
+
+// FieldsFromNode splits a node number into the values of the Layout's named
+// fields.  It returns nil if the Layout has no fields.
+func (l Layout) FieldsFromNode(node int64) map[string]int64 {
+
+	if len(l.Fields) == 0 {
+		return nil
+	}
+
+	values := make(map[string]int64, len(l.Fields))
+	for i := len(l.Fields) - 1; i >= 0; i-- {
+		f := l.Fields[i]
+		values[f.Name] = node & f.Max()
+		node >>= f.Bits
+	}
+
+	return values
+}
+
+// validateFields checks that the Layout's fields have unique names and add up
+// to its NodeBits.
+func (l Layout) validateFields() error {
+
+	if len(l.Fields) == 0 {
+		return nil
+	}
+
+	var bits int
+	for i, f := range l.Fields {
+		if f.Name == "" || l.field(f.Name) != i {
+			return ErrFieldName
+		}
+		bits += int(f.Bits)
+	}
+
+	if bits != int(l.NodeBits) {
+		return ErrFieldBits
+	}
+
+	return nil
+}
+
+// field returns the index of the named field, or -1 if there is none.
+func (l Layout) field(name string) int {
+	for i, f := range l.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
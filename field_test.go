@@ -0,0 +1,104 @@
+package snowflake
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLayoutBuilder(t *testing.T) {
+
+	epoch := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// the original twitter layout splits the node into datacenter and worker
+	l, err := NewLayoutBuilder(epoch).
+		Field("datacenter", 5).
+		Field("worker", 5).
+		StepBits(12).
+		Build()
+	if err != nil {
+		t.Fatalf("error building layout, %s", err)
+	}
+
+	want := Layout{
+		Epoch:    epoch,
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 12,
+		Fields:   []Field{{"datacenter", 5}, {"worker", 5}},
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Fatalf("layout %+v != %+v", l, want)
+	}
+
+	// instagram uses a 13 bit shard with 41 time bits
+	l, err = NewLayoutBuilder(epoch).TimeBits(41).Field("shard", 13).StepBits(10).Unsigned().Build()
+	if err != nil {
+		t.Fatalf("error building layout, %s", err)
+	}
+	if l.NodeBits != 13 || l.TimeBits != 41 {
+		t.Fatalf("unexpected bits %d/%d", l.TimeBits, l.NodeBits)
+	}
+
+	_, err = NewLayoutBuilder(epoch).Field("a", 5).Field("a", 5).Build()
+	if err != ErrFieldName {
+		t.Fatalf("error %v != %v", err, ErrFieldName)
+	}
+
+	_, err = NewLayoutBuilder(epoch).TimeBits(41).Field("a", 20).StepBits(12).Build()
+	if err != ErrLayoutTooLarge {
+		t.Fatalf("error %v != %v", err, ErrLayoutTooLarge)
+	}
+
+	l.NodeBits = 12
+	if err = l.Validate(); err != ErrFieldBits {
+		t.Fatalf("error %v != %v", err, ErrFieldBits)
+	}
+}
+
+func TestNodeFields(t *testing.T) {
+
+	l, err := NewLayoutBuilder(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)).
+		Field("datacenter", 3).
+		Field("worker", 4).
+		Field("shard", 3).
+		StepBits(12).
+		Build()
+	if err != nil {
+		t.Fatalf("error building layout, %s", err)
+	}
+
+	fields := map[string]int64{"datacenter": 5, "worker": 9, "shard": 2}
+
+	node, err := NewNodeWithConfig(Config{Layout: l, Fields: fields})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	p := node.Decompose(node.Generate())
+	if p.Node != 5<<7|9<<3|2 {
+		t.Fatalf("node %d != %d", p.Node, 5<<7|9<<3|2)
+	}
+	if !reflect.DeepEqual(p.Fields, fields) {
+		t.Fatalf("fields %v != %v", p.Fields, fields)
+	}
+
+	p.Fields["worker"] = 10
+	id, err := l.Compose(p)
+	if err != nil {
+		t.Fatalf("error composing, %s", err)
+	}
+	if got := l.Decompose(id).Fields["worker"]; got != 10 {
+		t.Fatalf("worker %d != 10", got)
+	}
+
+	_, err = NewNodeWithConfig(Config{Layout: l, Fields: map[string]int64{"rack": 1}})
+	if err != ErrUnknownField {
+		t.Fatalf("error %v != %v", err, ErrUnknownField)
+	}
+
+	_, err = NewNodeWithConfig(Config{Layout: l, Fields: map[string]int64{"worker": 16}})
+	if err != ErrFieldOutOfRange {
+		t.Fatalf("error %v != %v", err, ErrFieldOutOfRange)
+	}
+}
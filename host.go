@@ -0,0 +1,140 @@
+package snowflake
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+)
+
+var (
+	// ErrNoPrivateIP is returned when no private IPv4 address is available
+	// to derive a node number from.
+	ErrNoPrivateIP = errors.New("no private IPv4 address")
+
+	// ErrNoHardwareAddr is returned when no MAC address is available to
+	// derive a node number from.
+	ErrNoHardwareAddr = errors.New("no hardware address")
+)
+
+// privateNets are the IPv4 ranges set aside for private networks by RFC 1918.
+var privateNets = []net.IPNet{
+	{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(172, 16, 0, 0), Mask: net.CIDRMask(12, 32)},
+	{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(16, 32)},
+}
+
+// NodeFromIP returns a node number made from the low bits of the private
+// IPv4 address ip, along with a note on when the number can collide with
+// that of another host.
+func (l Layout) NodeFromIP(ip net.IP) (int64, string, error) {
+
+	ip4 := ip.To4()
+	if ip4 == nil || !isPrivate(ip4) {
+		return 0, "", ErrNoPrivateIP
+	}
+
+	node := int64(binary.BigEndian.Uint32(ip4)) & l.MaxNode()
+
+	if l.NodeBits >= 32 {
+		return node, fmt.Sprintf("node number is the whole address %s; hosts collide if they share a private address on different networks", ip4), nil
+	}
+
+	return node, fmt.Sprintf("node number is the low %d bits of %s; hosts collide unless they are all on one /%d subnet", l.NodeBits, ip4, 32-l.NodeBits), nil
+}
+
+// NodeFromMAC returns a node number made from the low bits of the MAC address
+// mac, along with a note on when the number can collide with that of another
+// host.
+func (l Layout) NodeFromMAC(mac net.HardwareAddr) (int64, string, error) {
+
+	if len(mac) == 0 {
+		return 0, "", ErrNoHardwareAddr
+	}
+
+	var b [8]byte
+	if len(mac) > len(b) {
+		mac = mac[len(mac)-len(b):]
+	}
+	copy(b[len(b)-len(mac):], mac)
+
+	node := int64(binary.BigEndian.Uint64(b[:])) & l.MaxNode()
+
+	return node, fmt.Sprintf("node number is the low %d bits of %s; MAC addresses are unique but their low bits are not, and virtual machines and containers may reuse them", l.NodeBits, mac), nil
+}
+
+// NodeFromName returns a node number made from a hash of name, such as a
+// hostname or pod name, along with a note on how likely the number is to
+// collide with that of another host.
+func (l Layout) NodeFromName(name string) (int64, string) {
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+
+	node := int64(h.Sum64()) & l.MaxNode()
+
+	return node, fmt.Sprintf("node number is a %d-bit hash of %q; any two names collide 1 time in %d, and a collision becomes likely at around %d names", l.NodeBits, name, l.MaxNode()+1, int64(1)<<((l.NodeBits+1)/2))
+}
+
+// NodeFromHostIP returns a node number made from the first private IPv4
+// address of the host, see NodeFromIP.
+func (l Layout) NodeFromHostIP() (int64, string, error) {
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return 0, "", err
+	}
+
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			if ip4 := ipnet.IP.To4(); ip4 != nil && isPrivate(ip4) {
+				return l.NodeFromIP(ip4)
+			}
+		}
+	}
+
+	return 0, "", ErrNoPrivateIP
+}
+
+// NodeFromHostMAC returns a node number made from the MAC address of the
+// host's first network interface that is up and not a loopback, see
+// NodeFromMAC.
+func (l Layout) NodeFromHostMAC() (int64, string, error) {
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, "", err
+	}
+
+	for _, i := range ifaces {
+		if i.Flags&net.FlagUp != 0 && i.Flags&net.FlagLoopback == 0 && len(i.HardwareAddr) > 0 {
+			return l.NodeFromMAC(i.HardwareAddr)
+		}
+	}
+
+	return 0, "", ErrNoHardwareAddr
+}
+
+// NodeFromHostname returns a node number made from a hash of the host's name,
+// which in Kubernetes is the pod name, see NodeFromName.
+func (l Layout) NodeFromHostname() (int64, string, error) {
+
+	name, err := os.Hostname()
+	if err != nil {
+		return 0, "", err
+	}
+
+	node, risk := l.NodeFromName(name)
+	return node, risk, nil
+}
+
+func isPrivate(ip net.IP) bool {
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
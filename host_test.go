@@ -0,0 +1,61 @@
+package snowflake
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNodeFromIP(t *testing.T) {
+
+	l := DefaultLayout()
+
+	node, risk, err := l.NodeFromIP(net.ParseIP("10.1.2.3"))
+	if err != nil {
+		t.Fatalf("error deriving node, %s", err)
+	}
+	if node != 0x203 || risk == "" {
+		t.Fatalf("node %#x, risk %q", node, risk)
+	}
+
+	for _, ip := range []string{"8.8.8.8", "fd00::1", "172.32.0.1"} {
+		if _, _, err := l.NodeFromIP(net.ParseIP(ip)); err != ErrNoPrivateIP {
+			t.Fatalf("%s: error %v != %v", ip, err, ErrNoPrivateIP)
+		}
+	}
+}
+
+func TestNodeFromMAC(t *testing.T) {
+
+	l := DefaultLayout()
+
+	mac, _ := net.ParseMAC("02:42:ac:11:07:ff")
+	node, risk, err := l.NodeFromMAC(mac)
+	if err != nil {
+		t.Fatalf("error deriving node, %s", err)
+	}
+	if node != 0x3ff || risk == "" {
+		t.Fatalf("node %#x, risk %q", node, risk)
+	}
+
+	if _, _, err := l.NodeFromMAC(nil); err != ErrNoHardwareAddr {
+		t.Fatalf("error %v != %v", err, ErrNoHardwareAddr)
+	}
+}
+
+func TestNodeFromName(t *testing.T) {
+
+	l := DefaultLayout()
+
+	a, risk := l.NodeFromName("web-0")
+	b, _ := l.NodeFromName("web-0")
+	if a != b || risk == "" {
+		t.Fatalf("node %d != %d, risk %q", a, b, risk)
+	}
+	if a < 0 || a > l.MaxNode() {
+		t.Fatalf("node %d out of range", a)
+	}
+
+	if _, _, err := l.NodeFromHostname(); err != nil {
+		t.Fatalf("error deriving node, %s", err)
+	}
+}
@@ -0,0 +1,428 @@
+package snowflake
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// ErrLayoutTooLarge is returned when the time, node and step bits of a Layout
+// add up to more than 63 bits, or 64 bits for an unsigned Layout.
+var ErrLayoutTooLarge = errors.New("layout uses too many bits")
+
+// ErrNoTimeBits is returned when a Layout does not reserve any bits for the
+// timestamp.
+var ErrNoTimeBits = errors.New("layout must have at least one time bit")
+
+// ErrNoEpoch is returned when a Layout does not have an epoch set.
+var ErrNoEpoch = errors.New("layout epoch must be set")
+
+// ErrInvalidTimeUnit is returned when a Layout has a negative time unit.
+var ErrInvalidTimeUnit = errors.New("layout time unit must be positive")
+
+// ErrTimeBeforeEpoch is returned when a time before the Layout epoch is used
+// to build an ID.
+var ErrTimeBeforeEpoch = errors.New("time is before the layout epoch")
+
+// ErrTimeOverflow is returned when a time does not fit in the time bits of a
+// Layout.
+var ErrTimeOverflow = errors.New("time overflows the layout time bits")
+
+// ErrNodeOutOfRange is returned when a node number does not fit in the node
+// bits of a Layout.
+var ErrNodeOutOfRange = errors.New("node number out of range for layout")
+
+// ErrStepOutOfRange is returned when a step number does not fit in the step
+// bits of a Layout.
+var ErrStepOutOfRange = errors.New("step number out of range for layout")
+
+// A Layout describes the format of the snowflake IDs generated by a Node.
+// It holds the epoch and resolution of the timestamp, and the number of
+// bits given to each of the time, node and step (sequence) fields.
+//
+// Every Node keeps its own copy of the Layout it was created with, so a
+// single program may use several layouts side by side.
+type Layout struct {
+	// Epoch is the instant timestamps are measured from.
+	Epoch time.Time
+
+	// TimeUnit is the resolution of the timestamp. Zero means millisecond.
+	// A larger unit makes the layout last longer, a smaller unit allows more
+	// IDs to be generated per second.  For example Sonyflake uses 10ms.
+	TimeUnit time.Duration
+
+	// TimeBits, NodeBits and StepBits hold the number of bits used for the
+	// timestamp, node number and step number. Together they may use at most
+	// 63 bits, or 64 bits if Unsigned is set.
+	TimeBits uint8
+	NodeBits uint8
+	StepBits uint8
+
+	// Unsigned allows the layout to use the sign bit, giving it 64 bits in
+	// total.  IDs from an unsigned layout should be handled as a UID, as
+	// they do not fit in an int64 once the top bit is set.
+	Unsigned bool
+
+	// Fields optionally splits the node number into named parts, listed
+	// from the most significant bits down.  If set, their bits must add up
+	// to NodeBits.  See LayoutBuilder.
+	Fields []Field
+}
+
+// A Config holds the settings used by NewNodeWithConfig to create a Node.
+type Config struct {
+	// Layout is the ID format the node generates.
+	Layout Layout
+
+	// Node is the node number, between 0 and Layout.MaxNode().
+	Node int64
+
+	// Fields holds the values of the Layout's named fields.  If set, the node
+	// number is built from them with Layout.NodeFromFields and Node is
+	// ignored.
+	Fields map[string]int64
+
+	// Clock is used to read the current time.  If nil, SystemClock is used.
+	Clock Clock
+
+	// Regression is what the node does when its clock moves backwards.
+	Regression RegressionPolicy
+
+	// MaxRegression, if set, is the largest clock regression the node will
+	// handle with its Regression policy.  Larger ones are always treated
+	// as RegressionError.
+	MaxRegression time.Duration
+
+	// Wait is how the node waits for its clock, for example when its step
+	// number is exhausted.  If nil, SpinWait is used.
+	Wait WaitStrategy
+
+	// StepStart sets the step number each time unit starts at.
+	StepStart StepStart
+
+	// MaxLead lets the node move its timestamp up to MaxLead ahead of its
+	// clock when the step number is exhausted, instead of waiting, so short
+	// bursts never block.  The clock then catches up while traffic is low.
+	// It is rounded down to a whole number of time units.
+	MaxLead time.Duration
+
+	// Observer, if set, is told about notable events of the node.
+	Observer Observer
+
+	// OverflowWarning, if set, makes the node send an EventOverflowWarning to
+	// its Observer once it is within OverflowWarning of running out of time
+	// bits.
+	OverflowWarning time.Duration
+
+	// Store, if set, persists a high-water mark for the node, so that after
+	// a restart it never generates IDs with a time before those it
+	// generated earlier.  A restarted node waits for its clock to pass the
+	// mark; only a clock more than Reserve behind it is handled as a clock
+	// regression, see Regression.  If saving the mark fails, the node keeps
+	// generating IDs up to the last saved mark and retries the save with a
+	// backoff; past the mark GenerateContext returns the error and Generate
+	// waits for a save to succeed.  Each node needs a StateStore of its own.
+	Store StateStore
+
+	// Reserve is how far ahead of the current time the node saves its
+	// high-water mark.  A larger value saves less often, but a restarted
+	// node may have to wait up to Reserve before generating IDs.  If zero,
+	// DefaultReserve is used.
+	Reserve time.Duration
+
+	// Backfill, if set, is used by Node.GenerateAt to generate IDs for past
+	// times.  It must use the same Layout as the node and a node number no
+	// live node uses.
+	Backfill *Backfill
+}
+
+// Parts holds the fields of a snowflake ID, as decoded by Layout.Decompose.
+type Parts struct {
+	// Time is the start of the time unit the ID was generated in.
+	Time time.Time
+
+	// Node is the node number of the ID.
+	Node int64
+
+	// Step is the step (or sequence) number of the ID.
+	Step int64
+
+	// Fields holds the value of each named field of the node number, if the
+	// Layout has any.
+	Fields map[string]int64
+}
+
+// layout returns a copy of the Config's Layout with its defaults filled in.
+func (c Config) layout() (Layout, error) {
+
+	if err := c.Layout.Validate(); err != nil {
+		return Layout{}, err
+	}
+
+	l := c.Layout
+	l.TimeUnit = l.unit()
+	l.Fields = append([]Field(nil), l.Fields...)
+
+	return l, nil
+}
+
+// node returns the node number set by the Config, checking that it fits in
+// the Config's Layout.
+func (c Config) node() (int64, error) {
+
+	node := c.Node
+
+	if c.Fields != nil {
+		var err error
+		if node, err = c.Layout.NodeFromFields(c.Fields); err != nil {
+			return 0, err
+		}
+	}
+
+	if node < 0 || node > c.Layout.MaxNode() {
+		return 0, errors.New("Node number must be between 0 and " + strconv.FormatInt(c.Layout.MaxNode(), 10))
+	}
+
+	return node, nil
+}
+
+// clock returns the Config's Clock, or SystemClock if it is not set.
+func (c Config) clock() Clock {
+	return clockOrSystem(c.Clock)
+}
+
+// clockOrSystem returns c, or SystemClock if c is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// DefaultLayout returns a Layout built from the package level Epoch, NodeBits
+// and StepBits values. The timestamp is given every bit not used by the node
+// and step numbers.
+func DefaultLayout() Layout {
+	return Layout{
+		Epoch:    time.Unix(Epoch/1000, (Epoch%1000)*1000000),
+		TimeUnit: time.Millisecond,
+		TimeBits: 63 - NodeBits - StepBits,
+		NodeBits: NodeBits,
+		StepBits: StepBits,
+	}
+}
+
+// Validate returns an error if the Layout cannot be used to generate IDs.
+func (l Layout) Validate() error {
+
+	if l.Epoch.IsZero() {
+		return ErrNoEpoch
+	}
+
+	if l.TimeUnit < 0 {
+		return ErrInvalidTimeUnit
+	}
+
+	if l.TimeBits == 0 {
+		return ErrNoTimeBits
+	}
+
+	if l.TimeBits > 63 || int(l.TimeBits)+int(l.NodeBits)+int(l.StepBits) > l.bits() {
+		return ErrLayoutTooLarge
+	}
+
+	return l.validateFields()
+}
+
+// MaxNode returns the largest node number the Layout can hold.
+func (l Layout) MaxNode() int64 {
+	return -1 ^ (-1 << l.NodeBits)
+}
+
+// MaxStep returns the largest step number the Layout can hold.
+func (l Layout) MaxStep() int64 {
+	return -1 ^ (-1 << l.StepBits)
+}
+
+// Decompose splits an ID into its time, node and step fields using the Layout.
+func (l Layout) Decompose(id ID) Parts {
+	return l.decompose(uint64(id))
+}
+
+// DecomposeUID splits a UID into its time, node and step fields using the
+// Layout.
+func (l Layout) DecomposeUID(id UID) Parts {
+	return l.decompose(uint64(id))
+}
+
+// Compose builds an ID from its time, node and step fields using the Layout.
+// The time is truncated to the Layout time unit.  If the Layout has named
+// fields and p.Fields is set, the node number is built from p.Fields.
+func (l Layout) Compose(p Parts) (ID, error) {
+	v, err := l.compose(p)
+	return ID(v), err
+}
+
+// ComposeUID builds a UID from its time, node and step fields using the
+// Layout.  The time is truncated to the Layout time unit.
+func (l Layout) ComposeUID(p Parts) (UID, error) {
+	v, err := l.compose(p)
+	return UID(v), err
+}
+
+func (l Layout) decompose(v uint64) Parts {
+
+	ticks := int64(v >> (l.NodeBits + l.StepBits) & uint64(l.maxTicks()))
+	node := int64(v>>l.StepBits) & l.MaxNode()
+
+	return Parts{
+		Time:   l.time(ticks),
+		Node:   node,
+		Step:   int64(v) & l.MaxStep(),
+		Fields: l.FieldsFromNode(node),
+	}
+}
+
+func (l Layout) compose(p Parts) (uint64, error) {
+
+	ticks := l.ticks(p.Time)
+	if ticks < 0 {
+		return 0, ErrTimeBeforeEpoch
+	}
+
+	if ticks > l.maxTicks() {
+		return 0, ErrTimeOverflow
+	}
+
+	if len(l.Fields) > 0 && p.Fields != nil {
+		node, err := l.NodeFromFields(p.Fields)
+		if err != nil {
+			return 0, err
+		}
+		p.Node = node
+	}
+
+	if p.Node < 0 || p.Node > l.MaxNode() {
+		return 0, ErrNodeOutOfRange
+	}
+
+	if p.Step < 0 || p.Step > l.MaxStep() {
+		return 0, ErrStepOutOfRange
+	}
+
+	return uint64(ticks)<<(l.NodeBits+l.StepBits) | uint64(p.Node)<<l.StepBits | uint64(p.Step), nil
+}
+
+// MinID returns the smallest ID the Layout can hold for the time unit that
+// contains t.  Together with MaxID it can be used to select the IDs generated
+// during a range of time, for example in a database query.
+func (l Layout) MinID(t time.Time) (ID, error) {
+	return l.Compose(Parts{Time: t})
+}
+
+// MaxID returns the largest ID the Layout can hold for the time unit that
+// contains t.
+func (l Layout) MaxID(t time.Time) (ID, error) {
+	return l.Compose(Parts{Time: t, Node: l.MaxNode(), Step: l.MaxStep()})
+}
+
+// Lifetime returns how long the Layout can generate IDs for, starting from
+// its epoch.  It returns the largest possible time.Duration if the lifetime
+// is too long to fit in one.
+func (l Layout) Lifetime() time.Duration {
+
+	unit := l.unit()
+	ticks := l.maxTicks()
+
+	if ticks >= int64(maxDuration/unit) {
+		return maxDuration
+	}
+
+	return time.Duration(ticks+1) * unit
+}
+
+// ExhaustionTime returns the first instant that no longer fits in the time
+// bits of the Layout.  Nodes using the Layout cannot generate valid IDs from
+// then on.
+func (l Layout) ExhaustionTime() time.Time {
+	return l.time(l.maxTicks()).Add(l.unit())
+}
+
+// maxTicks returns the largest timestamp the Layout can hold.
+func (l Layout) maxTicks() int64 {
+	return -1 ^ (-1 << l.TimeBits)
+}
+
+// bits returns the total number of bits available to the Layout.
+func (l Layout) bits() int {
+	if l.Unsigned {
+		return 64
+	}
+	return 63
+}
+
+// unit returns the time unit of the Layout, defaulting to millisecond.
+func (l Layout) unit() time.Duration {
+	if l.TimeUnit == 0 {
+		return time.Millisecond
+	}
+	return l.TimeUnit
+}
+
+// ticks returns the number of whole time units between the Layout epoch and t.
+// Times before the epoch give a negative result.
+func (l Layout) ticks(t time.Time) int64 {
+
+	unit := l.unit()
+
+	d := t.Sub(l.Epoch)
+	if d != maxDuration && d != minDuration {
+		q := int64(d / unit)
+		if d%unit < 0 {
+			q--
+		}
+		return q
+	}
+
+	// t is too far from the epoch to fit in a time.Duration.
+	ns := new(big.Int).SetInt64(t.Unix() - l.Epoch.Unix())
+	ns.Mul(ns, big.NewInt(int64(time.Second)))
+	ns.Add(ns, big.NewInt(int64(t.Nanosecond()-l.Epoch.Nanosecond())))
+	q := new(big.Int)
+	q.Div(ns, big.NewInt(int64(unit)))
+	if !q.IsInt64() {
+		if q.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return q.Int64()
+}
+
+// time returns the instant that is the given number of time units after the
+// Layout epoch.
+func (l Layout) time(ticks int64) time.Time {
+
+	unit := l.unit()
+	t := l.Epoch
+
+	// add in chunks so large tick counts do not overflow a time.Duration
+	chunk := int64(maxDuration / unit)
+	for ticks > chunk {
+		t = t.Add(time.Duration(chunk) * unit)
+		ticks -= chunk
+	}
+	for ticks < -chunk {
+		t = t.Add(-time.Duration(chunk) * unit)
+		ticks += chunk
+	}
+
+	return t.Add(time.Duration(ticks) * unit)
+}
+
+const (
+	maxDuration time.Duration = 1<<63 - 1
+	minDuration time.Duration = -1 << 63
+)
@@ -0,0 +1,329 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultLayout(t *testing.T) {
+
+	l := DefaultLayout()
+
+	if l.Epoch.UnixNano()/int64(time.Millisecond) != Epoch {
+		t.Fatalf("epoch %v does not match Epoch %d", l.Epoch, Epoch)
+	}
+
+	if l.TimeBits != 41 || l.NodeBits != 10 || l.StepBits != 12 {
+		t.Fatalf("unexpected bits %d/%d/%d", l.TimeBits, l.NodeBits, l.StepBits)
+	}
+
+	if l.MaxNode() != 1023 {
+		t.Fatalf("MaxNode %d != 1023", l.MaxNode())
+	}
+
+	if l.MaxStep() != 4095 {
+		t.Fatalf("MaxStep %d != 4095", l.MaxStep())
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		layout Layout
+		want   error
+	}{
+		{
+			name:   "ok",
+			layout: Layout{Epoch: epoch, TimeBits: 41, NodeBits: 10, StepBits: 12},
+			want:   nil,
+		},
+		{
+			name:   "no epoch",
+			layout: Layout{TimeBits: 41, NodeBits: 10, StepBits: 12},
+			want:   ErrNoEpoch,
+		},
+		{
+			name:   "negative time unit",
+			layout: Layout{Epoch: epoch, TimeUnit: -time.Millisecond, TimeBits: 41, NodeBits: 10, StepBits: 12},
+			want:   ErrInvalidTimeUnit,
+		},
+		{
+			name:   "no time bits",
+			layout: Layout{Epoch: epoch, NodeBits: 10, StepBits: 12},
+			want:   ErrNoTimeBits,
+		},
+		{
+			name:   "too many bits",
+			layout: Layout{Epoch: epoch, TimeBits: 42, NodeBits: 10, StepBits: 12},
+			want:   ErrLayoutTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.layout.Validate(); err != tt.want {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNodeWithConfig(t *testing.T) {
+
+	a, err := NewNodeWithConfig(Config{Layout: DefaultLayout(), Node: 1})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 39,
+		NodeBits: 16,
+		StepBits: 8,
+	}
+	b, err := NewNodeWithConfig(Config{Layout: l, Node: 40000})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	if b.Layout().TimeUnit != time.Millisecond {
+		t.Fatalf("TimeUnit %v != %v", b.Layout().TimeUnit, time.Millisecond)
+	}
+
+	// changing the package level values must not affect existing nodes
+	NodeBits, StepBits = 5, 5
+	defer func() { NodeBits, StepBits = 10, 12 }()
+
+	if a.Layout().NodeBits != 10 || a.Layout().StepBits != 12 {
+		t.Fatalf("node a layout changed to %+v", a.Layout())
+	}
+
+	id := b.Generate()
+	if node := int64(id) >> 8 & 0xFFFF; node != 40000 {
+		t.Fatalf("node %d != 40000", node)
+	}
+
+	_, err = NewNodeWithConfig(Config{Layout: l, Node: 1 << 16})
+	if err == nil {
+		t.Fatalf("no error creating NewNodeWithConfig with out of range node")
+	}
+
+	_, err = NewNodeWithConfig(Config{Layout: Layout{TimeBits: 41}})
+	if err != ErrNoEpoch {
+		t.Fatalf("error %v != %v", err, ErrNoEpoch)
+	}
+}
+
+func TestLayoutDecompose(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 43,
+		NodeBits: 8,
+		StepBits: 12,
+	}
+	node, err := NewNodeWithConfig(Config{Layout: l, Node: 200})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	before := time.Now().Truncate(time.Millisecond)
+	id := node.Generate()
+	after := time.Now()
+
+	p := node.Decompose(id)
+	if p.Time.Before(before) || p.Time.After(after) {
+		t.Fatalf("time %v not between %v and %v", p.Time, before, after)
+	}
+	if p.Node != 200 {
+		t.Fatalf("node %d != 200", p.Node)
+	}
+	if p.Step != 0 {
+		t.Fatalf("step %d != 0", p.Step)
+	}
+
+	cid, err := l.Compose(p)
+	if err != nil {
+		t.Fatalf("error composing, %s", err)
+	}
+	if cid != id {
+		t.Fatalf("composed %d != generated %d", cid, id)
+	}
+}
+
+func TestLayoutCompose(t *testing.T) {
+
+	l := DefaultLayout()
+
+	tests := []struct {
+		name  string
+		parts Parts
+		want  ID
+		err   error
+	}{
+		{
+			name:  "ok",
+			parts: Parts{Time: l.Epoch.Add(time.Second + time.Microsecond), Node: 3, Step: 7},
+			want:  ID(1000<<22 | 3<<12 | 7),
+		},
+		{
+			name:  "before epoch",
+			parts: Parts{Time: l.Epoch.Add(-time.Microsecond)},
+			err:   ErrTimeBeforeEpoch,
+		},
+		{
+			name:  "time overflow",
+			parts: Parts{Time: l.Epoch.Add(time.Duration(1<<41) * time.Millisecond)},
+			err:   ErrTimeOverflow,
+		},
+		{
+			name:  "node out of range",
+			parts: Parts{Time: l.Epoch, Node: 1024},
+			err:   ErrNodeOutOfRange,
+		},
+		{
+			name:  "step out of range",
+			parts: Parts{Time: l.Epoch, Step: -1},
+			err:   ErrStepOutOfRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Compose(tt.parts)
+			if err != tt.err {
+				t.Fatalf("Compose() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("Compose() got = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutLargeTimes(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeUnit: time.Second,
+		TimeBits: 41,
+	}
+
+	// 2^40 seconds is far beyond what a time.Duration can hold
+	ticks := int64(1 << 40)
+	tm := l.time(ticks)
+	if got := l.ticks(tm); got != ticks {
+		t.Fatalf("ticks %d != %d", got, ticks)
+	}
+	if got := l.ticks(tm.Add(-time.Nanosecond)); got != ticks-1 {
+		t.Fatalf("ticks %d != %d", got, ticks-1)
+	}
+	if got := l.ticks(l.Epoch.Add(-time.Nanosecond)); got != -1 {
+		t.Fatalf("ticks %d != -1", got)
+	}
+}
+
+func TestLayoutTimeUnit(t *testing.T) {
+
+	// 41 bits of microseconds only last about 25 days
+	epoch := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+
+	for _, unit := range []time.Duration{time.Microsecond, 10 * time.Millisecond, time.Second} {
+		t.Run(unit.String(), func(t *testing.T) {
+
+			l := Layout{Epoch: epoch, TimeUnit: unit, TimeBits: 41, NodeBits: 6, StepBits: 16}
+			node, err := NewNodeWithConfig(Config{Layout: l, Node: 5})
+			if err != nil {
+				t.Fatalf("error creating NewNodeWithConfig, %s", err)
+			}
+
+			before := time.Now()
+			var last ID
+			for i := 0; i < 1000; i++ {
+				id := node.Generate()
+				if id <= last {
+					t.Fatalf("id %d not greater than %d", id, last)
+				}
+				last = id
+			}
+			after := time.Now()
+
+			p := node.Decompose(last)
+			if p.Time.Before(before.Add(-unit)) || p.Time.After(after) {
+				t.Fatalf("time %v not between %v and %v", p.Time, before.Add(-unit), after)
+			}
+			if p.Time.Sub(epoch)%unit != 0 {
+				t.Fatalf("time %v is not a multiple of %v after the epoch", p.Time, unit)
+			}
+			if p.Node != 5 {
+				t.Fatalf("node %d != 5", p.Node)
+			}
+		})
+	}
+}
+
+func TestLayoutMinMaxID(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeUnit: 10 * time.Millisecond,
+		TimeBits: 39,
+		NodeBits: 16,
+		StepBits: 8,
+	}
+
+	tm := l.Epoch.Add(25 * time.Millisecond)
+
+	min, err := l.MinID(tm)
+	if err != nil {
+		t.Fatalf("error getting MinID, %s", err)
+	}
+	if min != ID(2<<24) {
+		t.Fatalf("MinID %d != %d", min, 2<<24)
+	}
+
+	max, err := l.MaxID(tm)
+	if err != nil {
+		t.Fatalf("error getting MaxID, %s", err)
+	}
+	if max != ID(3<<24-1) {
+		t.Fatalf("MaxID %d != %d", max, 3<<24-1)
+	}
+
+	if _, err := l.MinID(l.Epoch.Add(-time.Second)); err != ErrTimeBeforeEpoch {
+		t.Fatalf("error %v != %v", err, ErrTimeBeforeEpoch)
+	}
+}
+
+func TestLayoutLifetime(t *testing.T) {
+
+	l := DefaultLayout()
+
+	want := time.Duration(1<<41) * time.Millisecond
+	if got := l.Lifetime(); got != want {
+		t.Fatalf("Lifetime %v != %v", got, want)
+	}
+
+	// the twitter epoch and 41 bits run out in July 2080
+	exhausted := time.Date(2080, 7, 10, 17, 30, 30, 209000000, time.UTC)
+	if got := l.ExhaustionTime(); !got.Equal(exhausted) {
+		t.Fatalf("ExhaustionTime %v != %v", got.UTC(), exhausted)
+	}
+
+	// a lifetime too long for a time.Duration is capped
+	l.TimeUnit = time.Second
+	if got := l.Lifetime(); got != maxDuration {
+		t.Fatalf("Lifetime %v != %v", got, maxDuration)
+	}
+	if got := l.ExhaustionTime(); got.Year() < 70000 {
+		t.Fatalf("ExhaustionTime %v is too early", got)
+	}
+
+	if _, err := l.Compose(Parts{Time: l.ExhaustionTime()}); err != ErrTimeOverflow {
+		t.Fatalf("error %v != %v", err, ErrTimeOverflow)
+	}
+	if _, err := l.Compose(Parts{Time: l.ExhaustionTime().Add(-time.Second)}); err != nil {
+		t.Fatalf("error composing, %s", err)
+	}
+}
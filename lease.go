@@ -0,0 +1,239 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoFreeNode is returned by a Coordinator when every node number is
+	// already leased.
+	ErrNoFreeNode = errors.New("no free node number")
+
+	// ErrLeaseLost is returned once a lease has expired or been taken over,
+	// after which the node number may be in use elsewhere.
+	ErrLeaseLost = errors.New("node number lease lost")
+
+	// ErrClosed is returned by a LeasedNode once Close has been called.
+	ErrClosed = errors.New("leased node closed")
+)
+
+// A Lease gives its holder the use of a node number until it expires.
+type Lease struct {
+	Node    int64
+	Token   string
+	Expires time.Time
+}
+
+// A Coordinator hands out node numbers to generators as leases, so that
+// generators in an autoscaled fleet do not need node numbers assigned by
+// hand.  A Coordinator must never lease a node number to two holders at
+// once.
+type Coordinator interface {
+	// Acquire leases a free node number between 0 and maxNode for ttl, or
+	// returns ErrNoFreeNode.
+	Acquire(ctx context.Context, maxNode int64, ttl time.Duration) (Lease, error)
+
+	// Renew extends a lease to ttl from now, or returns ErrLeaseLost if the
+	// lease is no longer held.
+	Renew(ctx context.Context, l Lease, ttl time.Duration) (Lease, error)
+
+	// Release gives up a lease so its node number can be used again.
+	Release(ctx context.Context, l Lease) error
+}
+
+// A LeasedNode is a Node whose node number is leased from a Coordinator.  It
+// renews the lease in the background, and once the lease is lost it stops
+// generating IDs and returns ErrLeaseLost instead.
+//
+// A LeasedNode stops a tenth of the lease's ttl before the lease expires,
+// and discards any ID whose time falls in that margin, for example after a
+// long wait.  The expiry is checked against the node's Clock, so the
+// Coordinator and the node's clocks must agree to well within the margin.
+type LeasedNode struct {
+	node   *Node
+	coord  Coordinator
+	clock  Clock
+	ttl    time.Duration
+	margin time.Duration
+
+	stop context.CancelFunc
+	done chan struct{}
+
+	mu    sync.Mutex
+	lease Lease
+	err   error
+}
+
+// NewLeasedNode acquires a node number from coord for ttl and returns a
+// LeasedNode using it and the other settings in c.  The Node and Fields
+// settings of c are replaced.  The lease is renewed every third of ttl until
+// Close is called.
+func NewLeasedNode(ctx context.Context, c Config, coord Coordinator, ttl time.Duration) (*LeasedNode, error) {
+
+	l, err := c.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	lease, err := coord.Acquire(ctx, l.MaxNode(), ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Node = lease.Node
+	c.Fields = nil
+
+	node, err := NewNodeWithConfig(c)
+	if err != nil {
+		coord.Release(ctx, lease)
+		return nil, err
+	}
+
+	bg, stop := context.WithCancel(context.Background())
+
+	ln := LeasedNode{
+		node:   node,
+		coord:  coord,
+		clock:  c.clock(),
+		ttl:    ttl,
+		margin: ttl / 10,
+		stop:   stop,
+		done:   make(chan struct{}),
+		lease:  lease,
+	}
+
+	go ln.renew(bg)
+
+	return &ln, nil
+}
+
+func (ln *LeasedNode) renew(ctx context.Context) {
+
+	defer close(ln.done)
+
+	for {
+		if err := sleep(ctx, ln.ttl/3); err != nil {
+			return
+		}
+
+		ln.mu.Lock()
+		lease := ln.lease
+		ln.mu.Unlock()
+
+		lease, err := ln.coord.Renew(ctx, lease, ln.ttl)
+
+		// other errors may pass, so keep trying until the lease expires
+		if errors.Is(err, ErrLeaseLost) {
+			ln.lost()
+			return
+		}
+		if err == nil {
+			ln.mu.Lock()
+			ln.lease = lease
+			ln.mu.Unlock()
+		}
+	}
+}
+
+func (ln *LeasedNode) lost() {
+	ln.mu.Lock()
+	if ln.err == nil {
+		ln.err = ErrLeaseLost
+	}
+	ln.mu.Unlock()
+}
+
+// check returns an error if the lease is no longer held at t, allowing for
+// the node's margin.
+func (ln *LeasedNode) check(t time.Time) error {
+
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ln.err == nil && !t.Before(ln.lease.Expires.Add(-ln.margin)) {
+		ln.err = ErrLeaseLost
+	}
+
+	return ln.err
+}
+
+// Node returns the underlying Node.  IDs generated with it directly are not
+// checked against the lease.
+func (ln *LeasedNode) Node() *Node {
+	return ln.node
+}
+
+// Lease returns the current lease.
+func (ln *LeasedNode) Lease() Lease {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	return ln.lease
+}
+
+// Err returns ErrLeaseLost once the lease has been lost, or ErrClosed once
+// the node has been closed, and nil before then.
+func (ln *LeasedNode) Err() error {
+	return ln.check(ln.clock.Now())
+}
+
+// GenerateE creates and returns a unique snowflake ID, or an error if the
+// lease is no longer held.  See Node.GenerateE.
+func (ln *LeasedNode) GenerateE() (ID, error) {
+	return ln.GenerateContext(context.Background())
+}
+
+// GenerateContext creates and returns a unique snowflake ID, or an error if
+// the lease is no longer held.  See Node.GenerateContext.
+func (ln *LeasedNode) GenerateContext(ctx context.Context) (ID, error) {
+
+	if err := ln.check(ln.clock.Now()); err != nil {
+		return 0, err
+	}
+
+	id, err := ln.node.GenerateContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// the node may have waited, or borrowed time, past the lease
+	if err := ln.check(ln.node.Decompose(id).Time); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Close stops renewing the lease and, once the clock has passed the last time
+// unit the node used, releases it.  The LeasedNode returns ErrClosed from
+// then on.
+func (ln *LeasedNode) Close(ctx context.Context) error {
+
+	ln.stop()
+	<-ln.done
+
+	ln.mu.Lock()
+	lost := ln.err != nil
+	ln.err = ErrClosed
+	lease := ln.lease
+	ln.mu.Unlock()
+
+	if lost {
+		return nil
+	}
+
+	// the node number may be leased again once it is released, so wait
+	// until the clock has passed the last time unit the node used
+	n := ln.node
+	n.mu.Lock()
+	until := n.epoch.Add(time.Duration(n.time+1) * n.unit)
+	n.mu.Unlock()
+
+	if err := n.waiter.Wait(ctx, ln.clock, until); err != nil {
+		return err
+	}
+
+	return ln.coord.Release(ctx, lease)
+}
@@ -0,0 +1,156 @@
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestLeasedNode(t *testing.T) {
+
+	ctx := context.Background()
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	coord := NewMemoryCoordinator(clock)
+	c := Config{Layout: DefaultLayout(), Clock: clock}
+
+	a, err := NewLeasedNode(ctx, c, coord, 30*time.Millisecond)
+	if err != nil {
+		t.Fatalf("error creating NewLeasedNode, %s", err)
+	}
+	b, err := NewLeasedNode(ctx, c, coord, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating NewLeasedNode, %s", err)
+	}
+
+	id, err := b.GenerateE()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+	if p := b.Node().Decompose(id); p.Node != b.Lease().Node || p.Node == a.Lease().Node {
+		t.Fatalf("node %d, leases %d and %d", p.Node, a.Lease().Node, b.Lease().Node)
+	}
+
+	// once the lease is taken away the renewal fails
+	coord.Release(ctx, a.Lease())
+	for deadline := time.Now().Add(time.Second); a.Err() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatalf("lease loss not noticed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := a.GenerateE(); err != ErrLeaseLost {
+		t.Fatalf("error %v != %v", err, ErrLeaseLost)
+	}
+	if err := a.Close(ctx); err != nil {
+		t.Fatalf("error closing, %s", err)
+	}
+
+	// a lease that expires stops generating even before renewal fails
+	clock.Advance(2 * time.Hour)
+	if _, err := b.GenerateE(); err != ErrLeaseLost {
+		t.Fatalf("error %v != %v", err, ErrLeaseLost)
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("error closing, %s", err)
+	}
+	if _, err := b.GenerateE(); err != ErrClosed {
+		t.Fatalf("error %v != %v", err, ErrClosed)
+	}
+}
+
+func TestLeasedNodeWaitPastExpiry(t *testing.T) {
+
+	ctx := context.Background()
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	coord := NewMemoryCoordinator(clock)
+	c := Config{Layout: DefaultLayout(), Clock: clock, Regression: RegressionWait}
+
+	ln, err := NewLeasedNode(ctx, c, coord, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating NewLeasedNode, %s", err)
+	}
+	defer ln.stop()
+
+	if _, err := ln.GenerateE(); err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+
+	// the clock moves back, and by the time it has caught up the lease
+	// has expired
+	clock.Rewind(time.Second)
+	errs := make(chan error)
+	go func() {
+		_, err := ln.GenerateE()
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(2 * time.Hour)
+
+	if err := <-errs; err != ErrLeaseLost {
+		t.Fatalf("error %v != %v", err, ErrLeaseLost)
+	}
+
+	// the node stops a margin before the lease expires
+	ln, _ = NewLeasedNode(ctx, c, coord, time.Hour)
+	defer ln.stop()
+	clock.Advance(55 * time.Minute)
+	if _, err := ln.GenerateE(); err != ErrLeaseLost {
+		t.Fatalf("error %v != %v", err, ErrLeaseLost)
+	}
+}
+
+func TestLeasedNodeReacquire(t *testing.T) {
+
+	ctx := context.Background()
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	coord := NewMemoryCoordinator(clock)
+	c := Config{Layout: DefaultLayout(), Clock: clock}
+
+	a, err := NewLeasedNode(ctx, c, coord, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating NewLeasedNode, %s", err)
+	}
+	last, err := a.GenerateE()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+
+	// Close waits for the clock to pass the last time unit a used
+	clock.AutoAdvance(100 * time.Microsecond)
+	if err := a.Close(ctx); err != nil {
+		t.Fatalf("error closing, %s", err)
+	}
+	clock.Freeze()
+	if p := a.Node().Decompose(last); clock.Now().Before(p.Time.Add(time.Millisecond)) {
+		t.Fatalf("closed at %v, during the last time unit %v", clock.Now(), p.Time)
+	}
+
+	// the released node number is held back for the grace period
+	b, err := NewLeasedNode(ctx, c, coord, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating NewLeasedNode, %s", err)
+	}
+	defer b.stop()
+	if b.Lease().Node == a.Lease().Node {
+		t.Fatalf("node %d leased again straight away", b.Lease().Node)
+	}
+
+	clock.Advance(DefaultLeaseGrace)
+	again, err := NewLeasedNode(ctx, c, coord, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating NewLeasedNode, %s", err)
+	}
+	defer again.stop()
+	if again.Lease().Node != a.Lease().Node {
+		t.Fatalf("node %d != %d", again.Lease().Node, a.Lease().Node)
+	}
+	id, err := again.GenerateE()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+	if id <= last {
+		t.Fatalf("id %d not greater than %d", id, last)
+	}
+}
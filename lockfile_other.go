@@ -0,0 +1,32 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package snowflake
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// lockFile creates the file at path, waiting while it already exists, and
+// returns a function that removes it.  Without flock a lock file left behind
+// by a crashed process cannot be told apart from one in use, so it is never
+// broken; it has to be removed by hand.
+func lockFile(ctx context.Context, path string) (func(), error) {
+
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if err := sleep(ctx, time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
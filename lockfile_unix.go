@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package snowflake
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockFile takes an exclusive flock on the file at path, creating it if
+// needed, and waits while another process holds it.  It returns a function
+// that releases the lock.  The operating system releases the lock if the
+// process dies, so a crash never leaves the lock held.
+func lockFile(ctx context.Context, path string) (func(), error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return func() { file.Close() }, nil
+		}
+		if err != syscall.EWOULDBLOCK && err != syscall.EINTR {
+			file.Close()
+			return nil, err
+		}
+
+		if err := sleep(ctx, time.Millisecond); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package snowflake
+
+import "time"
+
+// An EventKind identifies the kind of an Event.
+type EventKind int
+
+const (
+	// EventCreated is sent once a Node has been created.
+	EventCreated EventKind = iota
+
+	// EventExhausted is sent when the step number ran out before the end of
+	// a time unit.  Event.Wait holds how long the node waited for its clock.
+	EventExhausted
+
+	// EventRegression is sent when the clock was seen moving backwards.
+	// Event.Drift holds how far it moved.
+	EventRegression
+
+	// EventOverflowWarning is sent once, when the node generates its first ID
+	// within Config.OverflowWarning of its Layout's ExhaustionTime.
+	// Event.Remaining holds how long the Layout has left.
+	EventOverflowWarning
+)
+
+// String returns the name of the EventKind.
+func (k EventKind) String() string {
+	switch k {
+	case EventCreated:
+		return "created"
+	case EventExhausted:
+		return "exhausted"
+	case EventRegression:
+		return "regression"
+	case EventOverflowWarning:
+		return "overflow warning"
+	}
+	return "unknown"
+}
+
+// An Event describes something notable a Node did.
+type Event struct {
+	Kind EventKind
+
+	// Node is the node number of the Node that sent the Event.
+	Node int64
+
+	// Time is the time of the last ID the Node generated, or the zero time
+	// if it has not generated any.
+	Time time.Time
+
+	// Wait is set for EventExhausted.
+	Wait time.Duration
+
+	// Drift is set for EventRegression.
+	Drift time.Duration
+
+	// Remaining is set for EventOverflowWarning.
+	Remaining time.Duration
+}
+
+// An Observer is told about notable Events of a Node, for example to log them
+// or raise alerts.  It is called while the Node's lock is held, so it should
+// return quickly and must not call the Node's methods.
+type Observer interface {
+	Observe(Event)
+}
+
+// An ObserverFunc is a function that can be used as an Observer.
+type ObserverFunc func(Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// notify sends an Event of the given kind to the node's Observer, if it has
+// one.  The caller must hold n.mu, apart from during NewNodeWithConfig.
+func (n *Node) notify(e Event) {
+
+	if n.observer == nil {
+		return
+	}
+
+	e.Node = n.node
+	if n.counters.generated > 0 {
+		e.Time = n.layout.time(n.time)
+	}
+	n.observer.Observe(e)
+}
@@ -0,0 +1,71 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestObserver(t *testing.T) {
+
+	var events []Event
+	observer := ObserverFunc(func(e Event) { events = append(events, e) })
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 32,
+		NodeBits: 10,
+		StepBits: 1,
+	}
+	// 32 bits of milliseconds run out after about 50 days
+	clock := snowflaketest.NewClock(l.ExhaustionTime().Add(-time.Hour))
+
+	node, err := NewNodeWithConfig(Config{
+		Layout:          l,
+		Node:            3,
+		Clock:           clock,
+		Regression:      RegressionLogical,
+		Observer:        observer,
+		OverflowWarning: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	node.Generate()
+	node.Generate()
+
+	clock.AutoAdvance(time.Millisecond)
+	node.Generate()
+	clock.Freeze()
+
+	clock.Rewind(5 * time.Millisecond)
+	node.Generate()
+
+	kinds := []EventKind{EventCreated, EventOverflowWarning, EventExhausted, EventRegression}
+	if len(events) != len(kinds) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(kinds), events)
+	}
+	for i, e := range events {
+		if e.Kind != kinds[i] {
+			t.Fatalf("event %d is %s, want %s", i, e.Kind, kinds[i])
+		}
+		if e.Node != 3 {
+			t.Fatalf("event %d is for node %d", i, e.Node)
+		}
+	}
+
+	if !events[0].Time.IsZero() {
+		t.Fatalf("created event has time %v", events[0].Time)
+	}
+	if r := events[1].Remaining; r != time.Hour {
+		t.Fatalf("Remaining %v != %v", r, time.Hour)
+	}
+	if w := events[2].Wait; w <= 0 {
+		t.Fatalf("Wait %v is not positive", w)
+	}
+	if d := events[3].Drift; d < 4*time.Millisecond {
+		t.Fatalf("Drift %v is too small", d)
+	}
+}
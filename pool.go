@@ -0,0 +1,100 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// A Pool spreads ID generation over several Nodes, each with its own node
+// number, so a process can generate more than MaxStep()+1 IDs per time unit.
+// IDs from a Pool are unique, but unlike IDs from a single Node they are not
+// generated in increasing order.
+//
+// A Pool uses the node numbers First() through Last().  Like any other node
+// numbers they must not be used anywhere else at the same time.
+type Pool struct {
+	next  uint32
+	nodes []*Node
+}
+
+var _ Generator = (*Pool)(nil)
+
+// NewPool returns a new Pool of size Nodes, numbered from c.Node upwards, all
+// using the rest of the settings in c.  If size is 0, runtime.GOMAXPROCS(0)
+// Nodes are used.  c.Fields and c.Store are not supported, as each Node needs
+// its own node number and StateStore.
+func NewPool(c Config, size int) (*Pool, error) {
+
+	if size == 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+
+	if size < 0 {
+		return nil, errors.New("Pool size must be positive")
+	}
+
+	if c.Fields != nil {
+		return nil, errors.New("Pool does not support Config.Fields")
+	}
+
+	if c.Store != nil {
+		return nil, errors.New("Pool does not support Config.Store")
+	}
+
+	if c.Node < 0 || c.Node+int64(size)-1 > c.Layout.MaxNode() {
+		return nil, errors.New("Pool node numbers must be between 0 and " + strconv.FormatInt(c.Layout.MaxNode(), 10))
+	}
+
+	p := Pool{nodes: make([]*Node, size)}
+	for i := range p.nodes {
+		nc := c
+		nc.Node = c.Node + int64(i)
+
+		n, err := NewNodeWithConfig(nc)
+		if err != nil {
+			return nil, err
+		}
+		p.nodes[i] = n
+	}
+
+	return &p, nil
+}
+
+// Size returns the number of Nodes, and so the number of node numbers, used
+// by the Pool.
+func (p *Pool) Size() int {
+	return len(p.nodes)
+}
+
+// First returns the first node number used by the Pool.
+func (p *Pool) First() int64 {
+	return p.nodes[0].node
+}
+
+// Last returns the last node number used by the Pool.
+func (p *Pool) Last() int64 {
+	return p.nodes[len(p.nodes)-1].node
+}
+
+// Node returns the next Node of the Pool, taking turns between them.  It
+// spreads callers across the Pool's Nodes, but does not give any caller a
+// Node of its own: once there are more callers than Size, they share Nodes.
+func (p *Pool) Node() *Node {
+	i := atomic.AddUint32(&p.next, 1)
+	return p.nodes[i%uint32(len(p.nodes))]
+}
+
+// Generate creates and returns a unique snowflake ID, using the Pool's Nodes
+// in turn.
+func (p *Pool) Generate() ID {
+	return p.Node().Generate()
+}
+
+// GenerateContext creates and returns a unique snowflake ID, using the Pool's
+// Nodes in turn.  See Node.GenerateContext.
+func (p *Pool) GenerateContext(ctx context.Context) (ID, error) {
+	return p.Node().GenerateContext(ctx)
+}
@@ -0,0 +1,112 @@
+package snowflake
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestNewPool(t *testing.T) {
+
+	p, err := NewPool(Config{Layout: DefaultLayout(), Node: 10}, 4)
+	if err != nil {
+		t.Fatalf("error creating NewPool, %s", err)
+	}
+
+	if p.Size() != 4 || p.First() != 10 || p.Last() != 13 {
+		t.Fatalf("pool uses %d nodes from %d to %d", p.Size(), p.First(), p.Last())
+	}
+
+	p, err = NewPool(Config{Layout: DefaultLayout()}, 0)
+	if err != nil {
+		t.Fatalf("error creating NewPool, %s", err)
+	}
+	if p.Size() < 1 {
+		t.Fatalf("pool size %d is less than 1", p.Size())
+	}
+
+	if _, err = NewPool(Config{Layout: DefaultLayout(), Node: 1020}, 5); err == nil {
+		t.Fatalf("no error creating NewPool past the last node number")
+	}
+
+	if _, err = NewPool(Config{Layout: DefaultLayout()}, -1); err == nil {
+		t.Fatalf("no error creating NewPool with a negative size")
+	}
+}
+
+func TestPoolRate(t *testing.T) {
+
+	// with a frozen clock each node can only give out 4 IDs
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 2,
+	}
+	p, err := NewPool(Config{Layout: l, Clock: clock}, 4)
+	if err != nil {
+		t.Fatalf("error creating NewPool, %s", err)
+	}
+
+	seen := make(map[ID]bool)
+	for i := 0; i < 16; i++ {
+		id := p.Generate()
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+
+		if pt := l.Decompose(id); !pt.Time.Equal(clock.Now()) {
+			t.Fatalf("time %v != %v", pt.Time, clock.Now())
+		}
+	}
+}
+
+func TestPoolParallel(t *testing.T) {
+
+	p, err := NewPool(Config{Layout: DefaultLayout()}, 4)
+	if err != nil {
+		t.Fatalf("error creating NewPool, %s", err)
+	}
+
+	const workers, count = 8, 10000
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[ID]bool, workers*count)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids := make([]ID, count)
+			for i := range ids {
+				ids[i] = p.Generate()
+			}
+			mu.Lock()
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkPoolParallel(b *testing.B) {
+
+	p, _ := NewPool(Config{Layout: DefaultLayout()}, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = p.Generate()
+		}
+	})
+}
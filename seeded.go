@@ -0,0 +1,105 @@
+package snowflake
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var _ Generator = (*SeededNode)(nil)
+
+// A SeededNode generates a repeatable sequence of IDs for tests, such as
+// golden-file tests that need the same IDs on every run.  It never reads a
+// clock; instead its time starts at a fixed instant and moves forward by a
+// pseudo-random number of time units derived from a seed.  The IDs are valid
+// for its Layout and can be decomposed like any other.
+//
+// Two SeededNodes with the same Config, seed and start generate the same IDs,
+// so a SeededNode must never be used alongside real Nodes.
+type SeededNode struct {
+	mu     sync.Mutex
+	layout Layout
+	rand   *rand.Rand
+	node   int64
+	time   int64
+	step   int64
+
+	stepMask  int64
+	timeShift uint8
+	nodeShift uint8
+}
+
+// NewSeededNode returns a new SeededNode that generates IDs using the layout
+// and node number held in c, starting at the time start.  Only the Layout,
+// Node and Fields settings of c are used, and c.Store is not supported.
+func NewSeededNode(c Config, seed int64, start time.Time) (*SeededNode, error) {
+
+	if c.Store != nil {
+		return nil, errors.New("SeededNode does not support Config.Store")
+	}
+
+	l, err := c.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := c.node()
+	if err != nil {
+		return nil, err
+	}
+
+	ticks := l.ticks(start)
+	if ticks < 0 {
+		return nil, ErrTimeBeforeEpoch
+	}
+	if ticks > l.maxTicks() {
+		return nil, ErrTimeOverflow
+	}
+
+	s := SeededNode{}
+	s.layout = l
+	s.rand = rand.New(rand.NewSource(seed))
+	s.node = node
+	s.time = ticks
+	s.stepMask = l.MaxStep()
+	s.step = s.stepMask
+	s.timeShift = l.NodeBits + l.StepBits
+	s.nodeShift = l.StepBits
+
+	return &s, nil
+}
+
+// Layout returns the Layout used by the node.
+func (s *SeededNode) Layout() Layout {
+	l := s.layout
+	l.Fields = append([]Field(nil), s.layout.Fields...)
+	return l
+}
+
+// Decompose splits an ID into its time, node and step fields using the
+// node's Layout.
+func (s *SeededNode) Decompose(id ID) Parts {
+	return s.layout.Decompose(id)
+}
+
+// Generate creates and returns the next ID in the node's sequence.  Each call
+// either moves on to the next step number in the same time unit, or moves
+// forward one or two time units and starts at a random step number.
+func (s *SeededNode) Generate() ID {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.step == s.stepMask || s.rand.Intn(2) == 0 {
+		s.time += 1 + s.rand.Int63n(2)
+		s.step = s.rand.Int63n(s.stepMask + 1)
+	} else {
+		s.step++
+	}
+
+	return ID(s.time<<s.timeShift |
+		(s.node << s.nodeShift) |
+		s.step,
+	)
+}
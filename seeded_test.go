@@ -0,0 +1,47 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeededNode(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	c := Config{Layout: DefaultLayout(), Node: 7}
+
+	a, err := NewSeededNode(c, 42, start)
+	if err != nil {
+		t.Fatalf("error creating NewSeededNode, %s", err)
+	}
+	b, _ := NewSeededNode(c, 42, start)
+	other, _ := NewSeededNode(c, 43, start)
+
+	same := true
+	var last ID
+	for i := 0; i < 1000; i++ {
+		id := a.Generate()
+		if id != b.Generate() {
+			t.Fatalf("ids from the same seed differ")
+		}
+		if id != other.Generate() {
+			same = false
+		}
+		if id <= last {
+			t.Fatalf("id %d not greater than %d", id, last)
+		}
+		last = id
+
+		p := a.Decompose(id)
+		if p.Node != 7 || p.Time.Before(start) {
+			t.Fatalf("decomposed %+v", p)
+		}
+	}
+	if same {
+		t.Fatalf("ids from different seeds are the same")
+	}
+
+	if _, err := NewSeededNode(c, 42, DefaultLayout().Epoch.Add(-time.Hour)); err != ErrTimeBeforeEpoch {
+		t.Fatalf("error %v != %v", err, ErrTimeBeforeEpoch)
+	}
+}
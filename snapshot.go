@@ -0,0 +1,74 @@
+package snowflake
+
+import "time"
+
+// A Snapshot holds the state a Node needs to carry on where another left off,
+// for example when a generator is handed over between processes during a
+// deploy.  It only holds exported fields, so it can be encoded with
+// encoding/json or encoding/gob.
+type Snapshot struct {
+	Layout Layout
+	Node   int64
+
+	// Time and Step are the time unit and step number of the last ID the
+	// Node generated.
+	Time time.Time
+	Step int64
+}
+
+// Snapshot returns the current state of the node.  Once a Snapshot has been
+// taken the node should stop generating IDs, as any it generates afterwards
+// could also be generated by a Node resumed from the Snapshot.
+func (n *Node) Snapshot() Snapshot {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return Snapshot{
+		Layout: n.Layout(),
+		Node:   n.node,
+		Time:   n.layout.time(n.time),
+		Step:   n.step,
+	}
+}
+
+// NewNodeFromSnapshot returns a new Node that resumes generating IDs from s,
+// using the other settings held in c.  The Layout, Node and Fields settings
+// of c are replaced by those in s.
+//
+// The new Node never reuses the time unit of the last ID in s, as the step
+// numbers still free in it depend on how the old Node was started.  If the
+// clock is behind s.Time, the Node handles it like any other clock
+// regression, see Config.Regression.
+func NewNodeFromSnapshot(s Snapshot, c Config) (*Node, error) {
+
+	if err := s.Layout.Validate(); err != nil {
+		return nil, err
+	}
+
+	if s.Step < 0 || s.Step > s.Layout.MaxStep() {
+		return nil, ErrStepOutOfRange
+	}
+
+	c.Layout = s.Layout
+	c.Node = s.Node
+	c.Fields = nil
+
+	n, err := NewNodeWithConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if mark := n.layout.ticks(s.Time); mark > n.time {
+		n.resume(mark)
+	}
+
+	return n, nil
+}
+
+// resume makes the node carry on after time unit mark, as if every step
+// number in it had been used.  The caller must hold n.mu or own the node.
+func (n *Node) resume(mark int64) {
+	n.time = mark
+	n.step = (n.start - 1) & n.stepMask
+}
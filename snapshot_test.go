@@ -0,0 +1,88 @@
+package snowflake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestSnapshot(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := snowflaketest.NewClock(start)
+	l := DefaultLayout()
+	l.Epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	old, err := NewNodeWithConfig(Config{Layout: l, Node: 5, Clock: clock, StepStart: StepStartRandom})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	var last ID
+	for i := 0; i < 10; i++ {
+		last = old.Generate()
+	}
+
+	b, err := json.Marshal(old.Snapshot())
+	if err != nil {
+		t.Fatalf("error marshalling, %s", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("error unmarshalling, %s", err)
+	}
+
+	p := old.Decompose(last)
+	if s.Node != 5 || !s.Time.Equal(p.Time) || s.Step != p.Step {
+		t.Fatalf("snapshot %+v does not match %+v", s, p)
+	}
+
+	node, err := NewNodeFromSnapshot(s, Config{Clock: clock})
+	if err != nil {
+		t.Fatalf("error creating NewNodeFromSnapshot, %s", err)
+	}
+	if !reflect.DeepEqual(node.Layout(), old.Layout()) {
+		t.Fatalf("layout %+v != %+v", node.Layout(), old.Layout())
+	}
+
+	// the clock has not moved, so the new node has to wait for it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	_, err = node.GenerateContext(ctx)
+	cancel()
+	if err != context.DeadlineExceeded {
+		t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+	}
+
+	clock.Advance(time.Millisecond)
+	id, err := node.GenerateE()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+	if id <= last {
+		t.Fatalf("id %d not greater than %d", id, last)
+	}
+
+	// a clock behind the snapshot is a clock regression
+	clock.Rewind(time.Second)
+	node, _ = NewNodeFromSnapshot(s, Config{Clock: clock})
+	var cerr ClockRegressionError
+	if _, err := node.GenerateE(); !errors.As(err, &cerr) {
+		t.Fatalf("error %v is not a ClockRegressionError", err)
+	}
+
+	// an invalid snapshot is rejected before the node is created
+	created := false
+	observer := ObserverFunc(func(e Event) { created = created || e.Kind == EventCreated })
+	s.Step = 1 << 12
+	if _, err := NewNodeFromSnapshot(s, Config{Clock: clock, Observer: observer}); err != ErrStepOutOfRange {
+		t.Fatalf("error %v != %v", err, ErrStepOutOfRange)
+	}
+	if created {
+		t.Fatalf("node created from an invalid snapshot")
+	}
+}
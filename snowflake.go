@@ -2,10 +2,13 @@
 package snowflake
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"strconv"
 	"sync"
 	"time"
@@ -48,6 +51,20 @@ func (j JSONSyntaxError) Error() string {
 	return fmt.Sprintf("invalid snowflake ID %q", string(j.original))
 }
 
+// A ClockRegressionError is returned when the clock reads earlier than the
+// time of the last ID a Node generated.
+type ClockRegressionError struct {
+	// Last is the time of the last ID generated.
+	Last time.Time
+
+	// Now is the time read from the clock.
+	Now time.Time
+}
+
+func (c ClockRegressionError) Error() string {
+	return fmt.Sprintf("clock moved backwards by %s", c.Last.Sub(c.Now))
+}
+
 // ErrInvalidBase58 is returned by ParseBase58 when given an invalid []byte
 var ErrInvalidBase58 = errors.New("invalid base58")
 
@@ -75,15 +92,81 @@ func init() {
 	}
 }
 
+// A RegressionPolicy decides what a Node does when its clock reads earlier
+// than the time of the last ID it generated, for example after the system
+// clock was stepped backwards.
+type RegressionPolicy int
+
+const (
+	// RegressionError makes GenerateContext return a ClockRegressionError.
+	// Generate cannot return an error, so it waits instead.
+	RegressionError RegressionPolicy = iota
+
+	// RegressionWait waits until the clock catches up with the last ID.
+	RegressionWait
+
+	// RegressionLogical keeps generating IDs with the time of the last ID,
+	// using up its remaining step numbers, as a logical clock would.  Once
+	// they run out the node waits for the clock to catch up.
+	RegressionLogical
+)
+
+// A Clock tells a Node the current time.  See the snowflaketest package for
+// a fake Clock that can be used in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock used when a Config does not set one.  It reads the
+// system time, and its monotonic clock reading when available, using
+// time.Now.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
 // A Node struct holds the basic information needed for a snowflake generator
 // node
 type Node struct {
-	mu    sync.Mutex
-	epoch time.Time
-	time  int64
-	node  int64
-	step  int64
-
+	mu     sync.Mutex
+	layout Layout
+	clock  Clock
+	waiter WaitStrategy
+	epoch  time.Time
+	unit   time.Duration
+	time   int64
+	node   int64
+	step   int64
+
+	// start is the first step number of the current time unit
+	start     int64
+	stepStart StepStart
+	rand      *rand.Rand
+
+	counters counters
+
+	observer Observer
+	warnAt   int64
+	warned   bool
+
+	store        StateStore
+	reserved     int64
+	reserveTicks int64
+	floor        int64
+	saveErr      error
+	saveBackoff  time.Duration
+	retryAt      time.Time
+
+	backfill *Backfill
+
+	regression    RegressionPolicy
+	maxRegression time.Duration
+	maxLead       int64
+
+	timeMax   int64
 	nodeMax   int64
 	nodeMask  int64
 	stepMask  int64
@@ -112,56 +195,369 @@ func NewNode(node int64) (*Node, error) {
 	nodeShift = StepBits
 	mu.Unlock()
 
+	return NewNodeWithConfig(Config{Layout: DefaultLayout(), Node: node})
+}
+
+// NewNodeWithConfig returns a new snowflake node that generates IDs using the
+// layout and node number held in c.  Unlike NewNode it does not read or
+// modify any of the package level variables.
+func NewNodeWithConfig(c Config) (*Node, error) {
+
+	l, err := c.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := c.node()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.MaxLead < 0:
+		return nil, errors.New("MaxLead must not be negative")
+	case c.Reserve < 0:
+		return nil, errors.New("Reserve must not be negative")
+	case c.OverflowWarning < 0:
+		return nil, errors.New("OverflowWarning must not be negative")
+	}
+
 	n := Node{}
+	n.layout = l
+	n.unit = l.TimeUnit
 	n.node = node
-	n.nodeMax = -1 ^ (-1 << NodeBits)
-	n.nodeMask = n.nodeMax << StepBits
-	n.stepMask = -1 ^ (-1 << StepBits)
-	n.timeShift = NodeBits + StepBits
-	n.nodeShift = StepBits
 
-	if n.node < 0 || n.node > n.nodeMax {
-		return nil, errors.New("Node number must be between 0 and " + strconv.FormatInt(n.nodeMax, 10))
+	n.timeMax = n.layout.maxTicks()
+	n.nodeMax = n.layout.MaxNode()
+	n.nodeMask = n.nodeMax << n.layout.StepBits
+	n.stepMask = n.layout.MaxStep()
+	n.timeShift = n.layout.NodeBits + n.layout.StepBits
+	n.nodeShift = n.layout.StepBits
+
+	n.regression = c.Regression
+	n.maxRegression = c.MaxRegression
+	n.maxLead = int64(c.MaxLead / n.unit)
+
+	n.clock = c.clock()
+	n.epoch = epoch(n.clock, n.layout.Epoch)
+
+	n.waiter = c.Wait
+	if n.waiter == nil {
+		n.waiter = SpinWait{}
 	}
 
-	var curTime = time.Now()
-	// add time.Duration to curTime to make sure we use the monotonic clock if available
-	n.epoch = curTime.Add(time.Unix(Epoch/1000, (Epoch%1000)*1000000).Sub(curTime))
+	n.stepStart = c.StepStart
+	if n.stepStart == StepStartRandom {
+		n.rand = rand.New(rand.NewSource(time.Now().UnixNano() ^ n.node))
+	}
+
+	n.store = c.Store
+	if n.store != nil {
+		reserve := c.Reserve
+		if reserve <= 0 {
+			reserve = DefaultReserve
+		}
+		n.reserveTicks = int64(reserve / n.unit)
+
+		if err := n.restore(); err != nil {
+			return nil, err
+		}
+	}
+
+	n.backfill = c.Backfill
+	if n.backfill != nil {
+		if !sameLayout(n.backfill.layout, n.layout) || n.backfill.node == n.node {
+			return nil, ErrBackfillConflict
+		}
+	}
+
+	n.observer = c.Observer
+	n.warnAt = n.timeMax - int64(c.OverflowWarning/n.unit)
+	if c.OverflowWarning <= 0 {
+		n.warnAt = math.MaxInt64
+	}
+	n.notify(Event{Kind: EventCreated})
 
 	return &n, nil
 }
 
+// epoch returns the given epoch with the monotonic clock reading of clock
+// attached, if it has one.
+func epoch(clock Clock, e time.Time) time.Time {
+	var curTime = clock.Now()
+	// add time.Duration to curTime to make sure we use the monotonic clock if available
+	return curTime.Add(e.Sub(curTime))
+}
+
+// Layout returns the Layout used by the node.
+func (n *Node) Layout() Layout {
+	l := n.layout
+	l.Fields = append([]Field(nil), n.layout.Fields...)
+	return l
+}
+
+// Lead returns how far ahead of its clock the node currently is, after
+// borrowing time units from the future as allowed by Config.MaxLead.
+func (n *Node) Lead() time.Duration {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	lead := n.epoch.Add(time.Duration(n.time) * n.unit).Sub(n.clock.Now())
+	if lead < 0 {
+		return 0
+	}
+
+	return lead
+}
+
+// Decompose splits an ID into its time, node and step fields using the
+// node's Layout.
+func (n *Node) Decompose(id ID) Parts {
+	return n.layout.Decompose(id)
+}
+
 // Generate creates and returns a unique snowflake ID
 // To help guarantee uniqueness
 // - Make sure your system is keeping accurate system time
 // - Make sure you never have multiple nodes running with the same node ID
+//
+// If the Layout's epoch is still in the future, Generate waits until it is
+// reached; GenerateContext returns ErrTimeBeforeEpoch instead.
 func (n *Node) Generate() ID {
 
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	now := time.Since(n.epoch).Milliseconds()
+	id, _ := n.generate(nil)
+	return id
+}
 
-	if now == n.time {
-		n.step = (n.step + 1) & n.stepMask
+// GenerateE creates and returns a unique snowflake ID like Generate, but
+// returns ErrTimeOverflow once the current time no longer fits in the
+// node's Layout, and ErrTimeBeforeEpoch if the Layout epoch is in the future.
+// It is the same as calling GenerateContext with context.Background().
+func (n *Node) GenerateE() (ID, error) {
+	return n.GenerateContext(context.Background())
+}
+
+// GenerateContext creates and returns a unique snowflake ID.  If the step
+// number is exhausted it waits for the next time unit, returning ctx.Err()
+// if ctx is done first.  If the clock reads earlier than the last ID the node
+// generated it follows the node's RegressionPolicy, and if the time no longer
+// fits in the node's Layout it returns ErrTimeOverflow.
+func (n *Node) GenerateContext(ctx context.Context) (ID, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
 
-		if n.step == 0 {
-			for now <= n.time {
-				now = time.Since(n.epoch).Milliseconds()
+	id, err := n.generate(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if n.time > n.timeMax {
+		return 0, ErrTimeOverflow
+	}
+
+	return id, nil
+}
+
+// generate creates the next ID.  A nil ctx keeps the behaviour of Generate,
+// which never fails.  The caller must hold n.mu.
+func (n *Node) generate(ctx context.Context) (ID, error) {
+
+	now := n.now()
+	read := now
+
+	if now < 0 && n.time <= 0 {
+		if ctx != nil {
+			return 0, ErrTimeBeforeEpoch
+		}
+
+		// Generate cannot return an error, so it waits for the epoch.  The
+		// clock has not moved backwards, so this is not a regression.
+		now, _ = n.wait(nil, 0)
+		read = now
+	}
+
+	if n.time <= n.floor && now <= n.floor {
+		var err error
+		if now, err = n.pastFloor(ctx, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if now < n.time {
+		if n.time-now <= n.maxLead {
+			// the node has borrowed time units from the future
+			now = n.time
+		} else {
+			var err error
+			if now, err = n.regressed(ctx, now, n.time); err != nil {
+				return 0, err
 			}
 		}
+	}
+
+	if now == n.time {
+		step := (n.step + 1) & n.stepMask
+
+		if step == n.start {
+			n.counters.exhaustions++
+
+			// wait until the next time unit is no more than maxLead ahead
+			var waited time.Duration
+			if next := n.time + 1; read < next-n.maxLead {
+				started := time.Now()
+				var err error
+				if now, err = n.wait(ctx, next-n.maxLead); err != nil {
+					return 0, err
+				}
+				waited = time.Since(started)
+			}
+			n.notify(Event{Kind: EventExhausted, Wait: waited})
+			if now <= n.time {
+				now = n.time + 1
+			}
+			step = n.begin()
+		}
+
+		n.step = step
 	} else {
-		n.step = 0
+		n.step = n.begin()
+	}
+
+	if n.store != nil {
+		if err := n.checkpoint(ctx, now); err != nil {
+			return 0, err
+		}
 	}
 
 	n.time = now
+	n.count()
+
+	if n.time >= n.warnAt && !n.warned {
+		n.warned = true
+		n.notify(Event{
+			Kind:      EventOverflowWarning,
+			Remaining: n.layout.ExhaustionTime().Sub(n.clock.Now()),
+		})
+	}
+
+	return n.id(), nil
+}
 
-	r := ID((now)<<n.timeShift |
+// id returns the ID for the node's current timestamp and step number.
+func (n *Node) id() ID {
+	return ID((n.time)<<n.timeShift |
 		(n.node << n.nodeShift) |
 		(n.step),
 	)
+}
+
+// regressed applies the node's RegressionPolicy when the clock reads now,
+// which is earlier than last, the latest timestamp the node's clock is known
+// to have reached, and returns the timestamp to use instead.  The caller must hold n.mu.
+func (n *Node) regressed(ctx context.Context, now, last int64) (int64, error) {
+
+	n.counters.regressions++
+	n.notify(Event{Kind: EventRegression, Drift: time.Duration(last-now) * n.unit})
+
+	policy := n.regression
+	if n.maxRegression > 0 && time.Duration(last-now)*n.unit > n.maxRegression {
+		policy = RegressionError
+	}
+
+	switch policy {
+	case RegressionWait:
+		return n.wait(ctx, last)
+	case RegressionLogical:
+		return last, nil
+	}
+
+	// Generate cannot return an error, so it waits instead.
+	if ctx == nil {
+		return n.wait(ctx, last)
+	}
+
+	return 0, ClockRegressionError{
+		Last: n.layout.time(last),
+		Now:  n.layout.time(now),
+	}
+}
+
+// wait waits until the node's clock reaches the given timestamp and returns
+// the timestamp it read.  The caller must hold n.mu.
+func (n *Node) wait(ctx context.Context, until int64) (int64, error) {
+
+	target := n.epoch.Add(time.Duration(until) * n.unit)
+
+	if ctx == nil {
+		ctx = context.Background()
+	} else if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < target.Sub(n.clock.Now()) {
+		// give up straight away if the deadline is too soon
+		return 0, context.DeadlineExceeded
+	}
+
+	started := time.Now()
+	defer func() { n.counters.waiting += time.Since(started) }()
+
+	now := n.now()
+	for now < until {
+		if err := n.waiter.Wait(ctx, n.clock, target); err != nil {
+			return 0, err
+		}
+		now = n.now()
+	}
+
+	return now, nil
+}
+
+// GenerateN creates and returns count unique snowflake IDs in increasing
+// order, see GenerateInto.
+func (n *Node) GenerateN(count int) []ID {
+	ids := make([]ID, count)
+	n.GenerateInto(ids)
+	return ids
+}
+
+// GenerateInto fills dst with unique snowflake IDs in increasing order,
+// unless the node uses a StepStart other than StepStartZero.  The node is
+// locked only once, and the step numbers left in each time unit are handed
+// out without reading the clock again, so it is much faster than calling
+// Generate in a loop.  Like Generate, it waits when the step number is
+// exhausted.
+func (n *Node) GenerateInto(dst []ID) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := 0; i < len(dst); {
+		dst[i], _ = n.generate(nil)
+		i++
+
+		for ; i < len(dst); i++ {
+			step := (n.step + 1) & n.stepMask
+			if step == n.start {
+				break
+			}
+			n.step = step
+			n.count()
+			dst[i] = n.id()
+		}
+	}
+}
+
+// GenerateUID creates and returns a unique snowflake ID as a UID.  Use it
+// instead of Generate when the node has an unsigned Layout.
+func (n *Node) GenerateUID() UID {
+	return UID(n.Generate())
+}
 
-	return r
+// now returns the number of time units elapsed since the node's epoch.
+func (n *Node) now() int64 {
+	return int64(n.clock.Now().Sub(n.epoch) / n.unit)
 }
 
 // Int64 returns an int64 of the snowflake ID
@@ -327,18 +723,21 @@ func ParseIntBytes(id [8]byte) ID {
 
 // Time returns an int64 unix timestamp in milliseconds of the snowflake ID time
 // DEPRECATED: the below function will be removed in a future release.
+// Use Layout.Decompose or Node.Decompose instead.
 func (f ID) Time() int64 {
 	return (int64(f) >> timeShift) + Epoch
 }
 
 // Node returns an int64 of the snowflake ID node number
 // DEPRECATED: the below function will be removed in a future release.
+// Use Layout.Decompose or Node.Decompose instead.
 func (f ID) Node() int64 {
 	return int64(f) & nodeMask >> nodeShift
 }
 
 // Step returns an int64 of the snowflake step (or sequence) number
 // DEPRECATED: the below function will be removed in a future release.
+// Use Layout.Decompose or Node.Decompose instead.
 func (f ID) Step() int64 {
 	return int64(f) & stepMask
 }
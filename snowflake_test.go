@@ -2,8 +2,13 @@ package snowflake
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
 )
 
 //******************************************************************************
@@ -39,6 +44,370 @@ func TestGenerateDuplicateID(t *testing.T) {
 	}
 }
 
+func TestGenerateE(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 20,
+		NodeBits: 10,
+		StepBits: 12,
+	}
+
+	// 20 bits of milliseconds last a little over 17 minutes
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1})
+	if _, err := node.GenerateE(); err != ErrTimeOverflow {
+		t.Fatalf("error %v != %v", err, ErrTimeOverflow)
+	}
+
+	l.Epoch = time.Now().Add(time.Hour)
+	node, _ = NewNodeWithConfig(Config{Layout: l, Node: 1})
+	if _, err := node.GenerateE(); err != ErrTimeBeforeEpoch {
+		t.Fatalf("error %v != %v", err, ErrTimeBeforeEpoch)
+	}
+
+	node, _ = NewNode(1)
+	id, err := node.GenerateE()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+	if id <= 0 {
+		t.Fatalf("invalid id %d", id)
+	}
+}
+
+func TestGenerateContext(t *testing.T) {
+
+	// with one step bit and an hour long time unit the third ID has to wait
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeUnit: time.Hour,
+		TimeBits: 40,
+		NodeBits: 10,
+		StepBits: 1,
+	}
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1})
+
+	var last ID
+	for i := 0; i < 2; i++ {
+		id, err := node.GenerateContext(context.Background())
+		if err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+		last = id
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := node.GenerateContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := node.GenerateContext(ctx); err != context.Canceled {
+		t.Fatalf("error %v != %v", err, context.Canceled)
+	}
+
+	// a failed wait must not give out a step number again
+	if node.time != int64(last)>>11 || node.step != 1 {
+		t.Fatalf("node state changed to time %d step %d", node.time, node.step)
+	}
+
+	// pretend the last ID was generated in the future
+	node.time += 2
+	_, err := node.GenerateContext(context.Background())
+	var cerr ClockRegressionError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("error %v is not a ClockRegressionError", err)
+	}
+	if d := cerr.Last.Sub(cerr.Now); d != 2*time.Hour {
+		t.Fatalf("regression %v != %v", d, 2*time.Hour)
+	}
+}
+
+func TestGenerateClock(t *testing.T) {
+
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 2,
+	}
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1, Clock: clock})
+
+	clock.Advance(time.Millisecond)
+	for i := 0; i < 4; i++ {
+		if _, err := node.GenerateE(); err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+	}
+
+	// the step number is exhausted and the clock is frozen
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := node.GenerateContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+	}
+
+	clock.AutoAdvance(100 * time.Microsecond)
+	id, err := node.GenerateE()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+	if p := node.Decompose(id); p.Time != l.Epoch.Add(time.Hour+2*time.Millisecond) || p.Step != 0 {
+		t.Fatalf("unexpected time %v step %d", p.Time, p.Step)
+	}
+	clock.Freeze()
+
+	clock.Rewind(time.Second)
+	var cerr ClockRegressionError
+	if _, err := node.GenerateE(); !errors.As(err, &cerr) {
+		t.Fatalf("error %v is not a ClockRegressionError", err)
+	}
+
+	clock.Set(l.ExhaustionTime())
+	if _, err := node.GenerateE(); err != ErrTimeOverflow {
+		t.Fatalf("error %v != %v", err, ErrTimeOverflow)
+	}
+}
+
+func TestRegressionPolicy(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 12,
+	}
+
+	setup := func(c Config) (*Node, *snowflaketest.Clock, ID) {
+		clock := snowflaketest.NewClock(start)
+		c.Layout, c.Clock = l, clock
+		node, err := NewNodeWithConfig(c)
+		if err != nil {
+			t.Fatalf("error creating NewNodeWithConfig, %s", err)
+		}
+		id, _ := node.GenerateE()
+		clock.Rewind(time.Second)
+		return node, clock, id
+	}
+
+	t.Run("error", func(t *testing.T) {
+		node, clock, last := setup(Config{Regression: RegressionError})
+
+		var cerr ClockRegressionError
+		if _, err := node.GenerateE(); !errors.As(err, &cerr) {
+			t.Fatalf("error %v is not a ClockRegressionError", err)
+		}
+
+		// Generate cannot fail, so it waits for the clock instead
+		clock.AutoAdvance(time.Millisecond)
+		if id := node.Generate(); id <= last {
+			t.Fatalf("id %d not greater than %d", id, last)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		node, clock, last := setup(Config{Regression: RegressionWait})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := node.GenerateContext(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+		}
+
+		clock.AutoAdvance(time.Millisecond)
+		id, err := node.GenerateE()
+		if err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+		if id <= last {
+			t.Fatalf("id %d not greater than %d", id, last)
+		}
+		if p := node.Decompose(id); p.Time.Before(start) {
+			t.Fatalf("time %v is before %v", p.Time, start)
+		}
+	})
+
+	t.Run("logical", func(t *testing.T) {
+		node, _, last := setup(Config{Regression: RegressionLogical})
+
+		id, err := node.GenerateE()
+		if err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+		if id != last+1 {
+			t.Fatalf("id %d != %d", id, last+1)
+		}
+	})
+
+	t.Run("max regression", func(t *testing.T) {
+		node, clock, _ := setup(Config{Regression: RegressionLogical, MaxRegression: 2 * time.Second})
+
+		if _, err := node.GenerateE(); err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+
+		clock.Rewind(2 * time.Second)
+		var cerr ClockRegressionError
+		if _, err := node.GenerateE(); !errors.As(err, &cerr) {
+			t.Fatalf("error %v is not a ClockRegressionError", err)
+		}
+	})
+}
+
+func TestGenerateN(t *testing.T) {
+
+	node, _ := NewNode(1)
+
+	ids := node.GenerateN(20000)
+	if len(ids) != 20000 {
+		t.Fatalf("got %d IDs, want 20000", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("id %d at %d not greater than %d", ids[i], i, ids[i-1])
+		}
+	}
+
+	// the next ID must follow the batch
+	if id := node.Generate(); id <= ids[len(ids)-1] {
+		t.Fatalf("id %d not greater than %d", id, ids[len(ids)-1])
+	}
+}
+
+func TestGenerateInto(t *testing.T) {
+
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	clock.AutoAdvance(100 * time.Microsecond)
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 3,
+	}
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1, Clock: clock})
+
+	dst := make([]ID, 20)
+	node.GenerateInto(dst)
+
+	// 20 IDs need three milliseconds with 8 steps each
+	for i, id := range dst {
+		p := node.Decompose(id)
+		if p.Step != int64(i%8) {
+			t.Fatalf("step %d at %d != %d", p.Step, i, i%8)
+		}
+		if i > 0 && id <= dst[i-1] {
+			t.Fatalf("id %d at %d not greater than %d", id, i, dst[i-1])
+		}
+	}
+
+	node.GenerateInto(nil)
+}
+
+func TestGenerateBeforeEpoch(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := snowflaketest.NewClock(start)
+	l := DefaultLayout()
+	l.Epoch = start.Add(time.Second)
+
+	var events []Event
+	node, _ := NewNodeWithConfig(Config{
+		Layout:   l,
+		Node:     1,
+		Clock:    clock,
+		Observer: ObserverFunc(func(e Event) { events = append(events, e) }),
+	})
+
+	// Generate waits for the epoch, which is not a clock regression
+	clock.AutoAdvance(time.Millisecond)
+	id := node.Generate()
+	clock.Freeze()
+	if p := node.Decompose(id); p.Time.Before(l.Epoch) {
+		t.Fatalf("time %v is before the epoch", p.Time)
+	}
+	if s := node.Stats(); s.Regressions != 0 {
+		t.Fatalf("%d regressions", s.Regressions)
+	}
+	for _, e := range events {
+		if e.Kind == EventRegression {
+			t.Fatalf("regression event %+v", e)
+		}
+	}
+}
+
+func TestNegativeDurations(t *testing.T) {
+
+	for _, c := range []Config{
+		{Layout: DefaultLayout(), MaxLead: -time.Millisecond},
+		{Layout: DefaultLayout(), Reserve: -time.Second},
+		{Layout: DefaultLayout(), OverflowWarning: -time.Hour},
+	} {
+		if _, err := NewNodeWithConfig(c); err == nil {
+			t.Fatalf("no error for %+v", c)
+		}
+	}
+}
+
+func TestMaxLead(t *testing.T) {
+
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 2,
+	}
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1, Clock: clock, MaxLead: 3 * time.Millisecond})
+
+	// the current millisecond and three borrowed ones give 16 IDs
+	var last ID
+	for i := 0; i < 16; i++ {
+		id, err := node.GenerateE()
+		if err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+		if id <= last {
+			t.Fatalf("id %d not greater than %d", id, last)
+		}
+		last = id
+	}
+
+	if lead := node.Lead(); lead != 3*time.Millisecond {
+		t.Fatalf("lead %v != %v", lead, 3*time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := node.GenerateContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+	}
+
+	// once the clock catches up a little the node can borrow again
+	clock.Advance(time.Millisecond)
+	if lead := node.Lead(); lead != 2*time.Millisecond {
+		t.Fatalf("lead %v != %v", lead, 2*time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		id, err := node.GenerateE()
+		if err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+		if id <= last {
+			t.Fatalf("id %d not greater than %d", id, last)
+		}
+		last = id
+	}
+
+	clock.Advance(time.Second)
+	if lead := node.Lead(); lead != 0 {
+		t.Fatalf("lead %v != 0", lead)
+	}
+}
+
 // I feel like there's probably a better way
 func TestRace(t *testing.T) {
 
@@ -434,6 +803,19 @@ func BenchmarkGenerate(b *testing.B) {
 	}
 }
 
+func BenchmarkGenerateN(b *testing.B) {
+
+	node, _ := NewNode(1)
+	ids := make([]ID, 1000)
+
+	b.ReportAllocs()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n += len(ids) {
+		node.GenerateInto(ids)
+	}
+}
+
 func BenchmarkGenerateMaxSequence(b *testing.B) {
 
 	NodeBits = 1
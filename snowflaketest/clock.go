@@ -0,0 +1,70 @@
+// Package snowflaketest provides helpers for testing code that uses the
+// snowflake package.
+package snowflaketest
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock is a fake clock that can be used as a snowflake.Clock.  It only
+// moves when told to, which makes it possible to test sequence exhaustion,
+// clock regression and epoch overflow without waiting on the real clock.
+//
+// A Clock is safe for concurrent use.
+type Clock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewClock returns a Clock that is frozen at t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+// Now returns the current time of the Clock, then moves the Clock forward by
+// the duration set with AutoAdvance.
+func (c *Clock) Now() time.Time {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now
+	c.now = c.now.Add(c.step)
+
+	return now
+}
+
+// Set sets the current time of the Clock.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// Advance moves the Clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Rewind moves the Clock backward by d.
+func (c *Clock) Rewind(d time.Duration) {
+	c.Advance(-d)
+}
+
+// AutoAdvance makes the Clock move forward by d every time Now is called.
+// This lets code that waits for the clock, such as a Node whose step number
+// is exhausted, make progress without another goroutine moving the Clock.
+func (c *Clock) AutoAdvance(d time.Duration) {
+	c.mu.Lock()
+	c.step = d
+	c.mu.Unlock()
+}
+
+// Freeze stops the Clock from moving on its own, undoing AutoAdvance.
+func (c *Clock) Freeze() {
+	c.AutoAdvance(0)
+}
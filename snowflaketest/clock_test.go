@@ -0,0 +1,63 @@
+package snowflaketest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+var _ snowflake.Clock = (*snowflaketest.Clock)(nil)
+
+func TestClock(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := snowflaketest.NewClock(start)
+
+	if now := c.Now(); !now.Equal(start) {
+		t.Fatalf("Now %v != %v", now, start)
+	}
+	if now := c.Now(); !now.Equal(start) {
+		t.Fatalf("frozen clock moved to %v", now)
+	}
+
+	c.Advance(time.Second)
+	if now := c.Now(); !now.Equal(start.Add(time.Second)) {
+		t.Fatalf("Now %v != %v", now, start.Add(time.Second))
+	}
+
+	c.Rewind(2 * time.Second)
+	if now := c.Now(); !now.Equal(start.Add(-time.Second)) {
+		t.Fatalf("Now %v != %v", now, start.Add(-time.Second))
+	}
+
+	c.Set(start)
+	c.AutoAdvance(time.Millisecond)
+	c.Now()
+	if now := c.Now(); !now.Equal(start.Add(time.Millisecond)) {
+		t.Fatalf("Now %v != %v", now, start.Add(time.Millisecond))
+	}
+
+	c.Freeze()
+	c.Now()
+	if now := c.Now(); !now.Equal(start.Add(2 * time.Millisecond)) {
+		t.Fatalf("Now %v != %v", now, start.Add(2*time.Millisecond))
+	}
+}
+
+func TestClockNode(t *testing.T) {
+
+	c := snowflaketest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := snowflake.DefaultLayout()
+
+	node, err := snowflake.NewNodeWithConfig(snowflake.Config{Layout: l, Node: 1, Clock: c})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	id := node.Generate()
+	if p := node.Decompose(id); !p.Time.Equal(c.Now()) {
+		t.Fatalf("time %v != %v", p.Time, c.Now())
+	}
+}
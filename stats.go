@@ -0,0 +1,75 @@
+package snowflake
+
+import "time"
+
+// Stats holds counters describing what a Node has done since it was created.
+type Stats struct {
+	// Generated is the number of IDs generated.
+	Generated uint64
+
+	// Exhaustions is the number of times the step number ran out before the
+	// end of a time unit.
+	Exhaustions uint64
+
+	// Waiting is the total time spent waiting for the clock, either after
+	// the step number ran out or after the clock moved backwards.
+	Waiting time.Duration
+
+	// Regressions is the number of times the clock was seen moving
+	// backwards.
+	Regressions uint64
+
+	// MaxStep is the highest step number reached in a single time unit, as
+	// counted from the step number the time unit started at.
+	MaxStep int64
+
+	// LastTime is the time of the last ID generated, or the zero time if
+	// none have been.
+	LastTime time.Time
+}
+
+// counters holds the values behind Stats.  They are only updated while the
+// node's lock is held, so they cost no more than an increment.
+type counters struct {
+	generated   uint64
+	exhaustions uint64
+	waiting     time.Duration
+	regressions uint64
+	maxStep     int64
+
+	// steps is the number of step numbers used in the current time unit
+	steps int64
+}
+
+// Stats returns a snapshot of the node's counters.
+func (n *Node) Stats() Stats {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s := Stats{
+		Generated:   n.counters.generated,
+		Exhaustions: n.counters.exhaustions,
+		Waiting:     n.counters.waiting,
+		Regressions: n.counters.regressions,
+		MaxStep:     n.counters.maxStep,
+	}
+
+	if n.counters.generated > 0 {
+		s.LastTime = n.layout.time(n.time)
+	}
+
+	return s
+}
+
+// count records that an ID was generated in the current time unit.  The
+// caller must hold n.mu.
+func (n *Node) count() {
+
+	n.counters.generated++
+	n.counters.steps++
+
+	if n.counters.steps-1 > n.counters.maxStep {
+		n.counters.maxStep = n.counters.steps - 1
+	}
+}
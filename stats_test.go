@@ -0,0 +1,62 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestStats(t *testing.T) {
+
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 2,
+	}
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1, Clock: clock, Regression: RegressionLogical})
+
+	if s := node.Stats(); s != (Stats{}) {
+		t.Fatalf("stats of a new node are %+v", s)
+	}
+
+	for i := 0; i < 3; i++ {
+		node.Generate()
+	}
+	node.GenerateN(1)
+
+	// the fifth ID exhausts the step number, move the clock on for it
+	clock.AutoAdvance(time.Millisecond)
+	node.Generate()
+	clock.Freeze()
+
+	clock.Rewind(10 * time.Millisecond)
+	node.Generate()
+
+	s := node.Stats()
+	if s.Generated != 6 {
+		t.Fatalf("Generated %d != 6", s.Generated)
+	}
+	if s.Exhaustions != 1 {
+		t.Fatalf("Exhaustions %d != 1", s.Exhaustions)
+	}
+	if s.Regressions != 1 {
+		t.Fatalf("Regressions %d != 1", s.Regressions)
+	}
+	if s.MaxStep != 3 {
+		t.Fatalf("MaxStep %d != 3", s.MaxStep)
+	}
+	if s.Waiting <= 0 {
+		t.Fatalf("Waiting %v is not positive", s.Waiting)
+	}
+
+	// the logical clock kept the time from before the regression
+	if p := node.Decompose(node.id()); !s.LastTime.Equal(p.Time) {
+		t.Fatalf("LastTime %v != %v", s.LastTime, p.Time)
+	}
+	if !s.LastTime.After(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("LastTime %v is too early", s.LastTime)
+	}
+}
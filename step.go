@@ -0,0 +1,39 @@
+package snowflake
+
+// A StepStart sets the step number a Node starts each time unit at.
+//
+// With StepStartZero, at low traffic almost every ID has a step number of
+// zero, which skews anything that shards on the low bits of an ID, such as
+// id % shards.  The other values spread the first step number of each time
+// unit, while still giving out every step number once before the node waits
+// for the next time unit.  IDs from the same time unit are then no longer in
+// increasing order, as the step number wraps around to zero part way through.
+type StepStart int
+
+const (
+	// StepStartZero starts each time unit at step number zero.
+	StepStartZero StepStart = iota
+
+	// StepStartRandom starts each time unit at a random step number.
+	StepStartRandom
+
+	// StepStartRotate starts each time unit one step number after where the
+	// previous one started.
+	StepStartRotate
+)
+
+// begin picks the first step number of a new time unit and returns it.  The
+// caller must hold n.mu.
+func (n *Node) begin() int64 {
+
+	n.counters.steps = 0
+
+	switch n.stepStart {
+	case StepStartRandom:
+		n.start = n.rand.Int63() & n.stepMask
+	case StepStartRotate:
+		n.start = (n.start + 1) & n.stepMask
+	}
+
+	return n.start
+}
@@ -0,0 +1,94 @@
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestStepStart(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 3,
+	}
+
+	for _, start := range []StepStart{StepStartZero, StepStartRandom, StepStartRotate} {
+
+		clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+		node, err := NewNodeWithConfig(Config{Layout: l, Node: 1, Clock: clock, StepStart: start})
+		if err != nil {
+			t.Fatalf("error creating NewNodeWithConfig, %s", err)
+		}
+
+		firsts := make(map[int64]bool)
+		for tick := 0; tick < 20; tick++ {
+			clock.Advance(time.Millisecond)
+
+			// every step number is given out once per time unit
+			steps := make(map[int64]bool)
+			for i := 0; i < 8; i++ {
+				id, err := node.GenerateE()
+				if err != nil {
+					t.Fatalf("error generating, %s", err)
+				}
+				p := node.Decompose(id)
+				if steps[p.Step] {
+					t.Fatalf("step %d given out twice", p.Step)
+				}
+				steps[p.Step] = true
+				if i == 0 {
+					firsts[p.Step] = true
+				}
+			}
+
+			// then the node has to wait for the next time unit
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			_, err := node.GenerateContext(ctx)
+			cancel()
+			if err != context.DeadlineExceeded {
+				t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+			}
+		}
+
+		switch start {
+		case StepStartZero:
+			if len(firsts) != 1 || !firsts[0] {
+				t.Fatalf("time units started at %v", firsts)
+			}
+		case StepStartRandom:
+			if len(firsts) < 2 {
+				t.Fatalf("time units started at %v", firsts)
+			}
+		case StepStartRotate:
+			if len(firsts) != 8 {
+				t.Fatalf("time units started at %v", firsts)
+			}
+		}
+	}
+}
+
+func TestStepStartGenerateInto(t *testing.T) {
+
+	clock := snowflaketest.NewClock(time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC))
+	clock.AutoAdvance(100 * time.Microsecond)
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 41,
+		NodeBits: 10,
+		StepBits: 3,
+	}
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1, Clock: clock, StepStart: StepStartRandom})
+
+	seen := make(map[ID]bool)
+	for _, id := range node.GenerateN(100) {
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,207 @@
+package snowflake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A StateStore persists a high-water mark for a Node: a time at or after the
+// last ID the Node generated.  A Node created with a StateStore will not
+// generate IDs with a time before the stored mark, so a restart after the
+// system clock was stepped backwards cannot repeat IDs from before the
+// restart.
+//
+// Each Node needs a StateStore of its own.
+type StateStore interface {
+	// Load returns the stored mark, or the zero time if there is none.
+	Load() (time.Time, error)
+
+	// Save stores a new mark.
+	Save(time.Time) error
+}
+
+// DefaultReserve is how far ahead of the current time a Node saves its
+// high-water mark when Config.Reserve is not set.
+const DefaultReserve = 3 * time.Second
+
+// A FileStore is a StateStore that keeps the mark in a file.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore returns a FileStore that keeps the mark in the file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load implements StateStore.  A missing file is treated as no mark.
+func (f *FileStore) Load() (time.Time, error) {
+
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var t time.Time
+	if err := t.UnmarshalText(bytes.TrimSpace(b)); err != nil {
+		return time.Time{}, err
+	}
+
+	return t, nil
+}
+
+// Save implements StateStore.  The mark is written to a temporary file which
+// is then renamed over the old one, so a crash never leaves a partial mark.
+func (f *FileStore) Save(t time.Time) error {
+
+	b, err := t.UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+
+	return writeFile(f.Path, append(b, '\n'))
+}
+
+// writeFile writes b to a temporary file, then renames it to path, so
+// readers never see a partly written file.
+func writeFile(path string, b []byte) error {
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// restore sets the node's floor to the mark held in its StateStore.  The
+// previous node may have used any time unit up to the mark, so the node
+// will not generate IDs until its clock has passed it.
+func (n *Node) restore() error {
+
+	t, err := n.store.Load()
+	if err != nil {
+		return err
+	}
+
+	if t.IsZero() {
+		return nil
+	}
+
+	if mark := n.layout.ticks(t); mark > n.time {
+		n.floor = mark
+		n.reserved = mark
+	}
+
+	return nil
+}
+
+// pastFloor returns a timestamp after the floor restored from the node's
+// StateStore.  The mark was saved Reserve ahead of the last time the previous
+// node reached, so only a clock behind that time is a clock regression;
+// otherwise the node just waits for the clock to pass the mark.  The caller
+// must hold n.mu.
+func (n *Node) pastFloor(ctx context.Context, now int64) (int64, error) {
+
+	if reached := n.floor - n.reserveTicks; now < reached {
+		var err error
+		if now, err = n.regressed(ctx, now, reached); err != nil {
+			return 0, err
+		}
+		if n.regression == RegressionLogical {
+			return n.floor + 1, nil
+		}
+	}
+
+	if now <= n.floor {
+		return n.wait(ctx, n.floor+1)
+	}
+
+	return now, nil
+}
+
+// Backoff limits between attempts to save the high-water mark after a
+// StateStore fails.
+const (
+	minSaveBackoff = 10 * time.Millisecond
+	maxSaveBackoff = time.Second
+)
+
+// checkpoint saves a new high-water mark once now is halfway through the
+// reserved time, so that a failing StateStore is noticed while IDs can still
+// be generated up to the saved mark.  Failed saves are retried with a
+// backoff.  Once now is past the saved mark, GenerateContext returns the
+// error and Generate waits until a save succeeds, so the node never gets
+// ahead of its saved mark.  The caller must hold n.mu.
+func (n *Node) checkpoint(ctx context.Context, now int64) error {
+
+	for now > n.reserved-n.reserveTicks/2 {
+
+		err := n.saveErr
+		if !time.Now().Before(n.retryAt) {
+			if err = n.reserve(now); err == nil {
+				return nil
+			}
+		}
+
+		if now <= n.reserved {
+			return nil
+		}
+
+		if ctx != nil {
+			return fmt.Errorf("saving high-water mark: %w", err)
+		}
+
+		// Generate cannot return an error, so it waits to try again
+		sleep(context.Background(), time.Until(n.retryAt))
+	}
+
+	return nil
+}
+
+// reserve saves a new high-water mark, reserve ahead of now.  The caller
+// must hold n.mu.
+func (n *Node) reserve(now int64) error {
+
+	mark := now + n.reserveTicks
+	if err := n.store.Save(n.layout.time(mark)); err != nil {
+		n.saveBackoff *= 2
+		if n.saveBackoff < minSaveBackoff {
+			n.saveBackoff = minSaveBackoff
+		}
+		if n.saveBackoff > maxSaveBackoff {
+			n.saveBackoff = maxSaveBackoff
+		}
+		n.saveErr = err
+		n.retryAt = time.Now().Add(n.saveBackoff)
+		return err
+	}
+
+	n.reserved = mark
+	n.saveErr = nil
+	n.saveBackoff = 0
+	return nil
+}
@@ -0,0 +1,232 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestFileStore(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "snowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := NewFileStore(filepath.Join(dir, "node.mark"))
+
+	mark, err := f.Load()
+	if err != nil {
+		t.Fatalf("error loading, %s", err)
+	}
+	if !mark.IsZero() {
+		t.Fatalf("missing file loaded as %v", mark)
+	}
+
+	want := time.Date(2020, 1, 1, 1, 2, 3, 4000000, time.UTC)
+	if err := f.Save(want); err != nil {
+		t.Fatalf("error saving, %s", err)
+	}
+	if mark, err = f.Load(); err != nil || !mark.Equal(want) {
+		t.Fatalf("loaded %v, %v, want %v", mark, err, want)
+	}
+
+	if err := os.WriteFile(f.Path, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Load(); err == nil {
+		t.Fatalf("no error loading a corrupt file")
+	}
+}
+
+// memoryStore is a StateStore that keeps the mark in memory.  Save fails
+// with err while it is set, and with errSave the next failures times.
+type memoryStore struct {
+	mark     time.Time
+	saves    int
+	attempts int
+	failures int
+	err      error
+}
+
+var errSave = errors.New("save failed")
+
+func (m *memoryStore) Load() (time.Time, error) { return m.mark, nil }
+
+func (m *memoryStore) Save(t time.Time) error {
+	m.attempts++
+	if m.err != nil {
+		return m.err
+	}
+	if m.failures > 0 {
+		m.failures--
+		return errSave
+	}
+	m.mark = t
+	m.saves++
+	return nil
+}
+
+func TestNodeStore(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := snowflaketest.NewClock(start)
+	store := &memoryStore{}
+	c := Config{Layout: DefaultLayout(), Node: 1, Clock: clock, Store: store, Reserve: time.Second}
+
+	node, err := NewNodeWithConfig(c)
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	last := node.Generate()
+	if !store.mark.Equal(start.Add(time.Second)) {
+		t.Fatalf("mark %v != %v", store.mark, start.Add(time.Second))
+	}
+
+	// nothing is saved until the node passes the reserved time
+	clock.Advance(500 * time.Millisecond)
+	node.Generate()
+	if store.saves != 1 {
+		t.Fatalf("saved %d times", store.saves)
+	}
+	clock.Advance(time.Second)
+	last = node.Generate()
+	if store.saves != 2 {
+		t.Fatalf("saved %d times", store.saves)
+	}
+
+	// restart after the clock was stepped back a minute
+	clock.Rewind(time.Minute)
+	node, err = NewNodeWithConfig(c)
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	var cerr ClockRegressionError
+	if _, err := node.GenerateE(); !errors.As(err, &cerr) {
+		t.Fatalf("error %v is not a ClockRegressionError", err)
+	}
+
+	// Generate waits until the clock passes the mark
+	clock.AutoAdvance(10 * time.Millisecond)
+	id := node.Generate()
+	clock.Freeze()
+	if id <= last {
+		t.Fatalf("id %d not greater than %d", id, last)
+	}
+	if p := node.Decompose(id); p.Time.Before(start.Add(2500 * time.Millisecond)) {
+		t.Fatalf("time %v is before the mark", p.Time)
+	}
+
+	store.err = errors.New("disk full")
+	clock.Advance(time.Minute)
+	if _, err := node.GenerateE(); !errors.Is(err, store.err) {
+		t.Fatalf("error %v is not %v", err, store.err)
+	}
+}
+
+func TestNodeStoreRestart(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := snowflaketest.NewClock(start)
+	store := &memoryStore{}
+	c := Config{Layout: DefaultLayout(), Node: 1, Clock: clock, Store: store, Reserve: time.Second}
+
+	node, _ := NewNodeWithConfig(c)
+	last := node.Generate()
+
+	// a quick restart with a healthy clock is not a clock regression
+	var events []Event
+	c.Observer = ObserverFunc(func(e Event) { events = append(events, e) })
+	clock.Advance(100 * time.Millisecond)
+	node, _ = NewNodeWithConfig(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err := node.GenerateContext(ctx)
+	cancel()
+	if err != context.DeadlineExceeded {
+		t.Fatalf("error %v != %v", err, context.DeadlineExceeded)
+	}
+
+	clock.AutoAdvance(10 * time.Millisecond)
+	id, err := node.GenerateE()
+	clock.Freeze()
+	if err != nil {
+		t.Fatalf("error generating, %s", err)
+	}
+	if id <= last {
+		t.Fatalf("id %d not greater than %d", id, last)
+	}
+	if p := node.Decompose(id); !p.Time.After(start.Add(time.Second)) {
+		t.Fatalf("time %v is not after the mark", p.Time)
+	}
+
+	if s := node.Stats(); s.Regressions != 0 {
+		t.Fatalf("%d regressions", s.Regressions)
+	}
+	for _, e := range events {
+		if e.Kind == EventRegression {
+			t.Fatalf("regression event %+v", e)
+		}
+	}
+}
+
+func TestNodeStoreFailure(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := snowflaketest.NewClock(start)
+	store := &memoryStore{}
+	c := Config{Layout: DefaultLayout(), Node: 1, Clock: clock, Store: store, Reserve: time.Second}
+
+	node, _ := NewNodeWithConfig(c)
+	node.Generate()
+	mark := store.mark
+
+	// within the saved mark IDs are still generated, and failed saves are
+	// retried on a backoff rather than on every call
+	store.failures = 4
+	clock.Advance(600 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		if _, err := node.GenerateE(); err != nil {
+			t.Fatalf("error generating, %s", err)
+		}
+	}
+	if store.attempts > 3 {
+		t.Fatalf("%d save attempts", store.attempts)
+	}
+
+	// past the mark GenerateE fails, and Generate waits for a save
+	clock.Advance(time.Second)
+	if _, err := node.GenerateE(); !errors.Is(err, errSave) {
+		t.Fatalf("error %v is not %v", err, errSave)
+	}
+	id := node.Generate()
+	if p := node.Decompose(id); !store.mark.After(p.Time) || !store.mark.After(mark) {
+		t.Fatalf("id time %v not covered by mark %v", p.Time, store.mark)
+	}
+}
+
+func TestStoreUnsupported(t *testing.T) {
+
+	c := Config{Layout: DefaultLayout(), Node: 1, Store: &memoryStore{}}
+
+	if _, err := NewAtomicNode(c); err == nil {
+		t.Fatalf("no error from NewAtomicNode")
+	}
+	if _, err := NewSeededNode(c, 1, time.Now()); err == nil {
+		t.Fatalf("no error from NewSeededNode")
+	}
+	if _, err := NewBackfill(c); err == nil {
+		t.Fatalf("no error from NewBackfill")
+	}
+	if _, err := NewPool(c, 2); err == nil {
+		t.Fatalf("no error from NewPool")
+	}
+}
@@ -0,0 +1,96 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrStreamClosed is returned by Stream.Next once the Stream has stopped and
+// every buffered ID has been received.
+var ErrStreamClosed = errors.New("stream closed")
+
+// A Stream generates IDs ahead of time in a background goroutine, so they
+// can be received from a buffered channel without waiting on the Node's
+// lock.
+//
+// An ID holds the time it was generated, not the time it was received, so
+// buffered IDs get older the longer they wait in the channel.  How old they
+// can get depends on how quickly they are received; use Age to see the age of
+// an ID, or Next to skip IDs that are too old.
+type Stream struct {
+	// C delivers the generated IDs.  It is closed once the Stream stops.
+	C <-chan ID
+
+	node *Node
+
+	mu  sync.Mutex
+	err error
+}
+
+// Stream starts generating IDs into a channel with room for size IDs, using
+// GenerateContext.  It stops when ctx is done, or when GenerateContext
+// returns an error, see Stream.Err.
+func (n *Node) Stream(ctx context.Context, size int) *Stream {
+
+	ids := make(chan ID, size)
+	s := Stream{C: ids, node: n}
+
+	go s.run(ctx, ids)
+
+	return &s
+}
+
+func (s *Stream) run(ctx context.Context, ids chan<- ID) {
+
+	defer close(ids)
+
+	for {
+		id, err := s.node.GenerateContext(ctx)
+		if err != nil {
+			s.stop(err)
+			return
+		}
+
+		select {
+		case ids <- id:
+		case <-ctx.Done():
+			s.stop(ctx.Err())
+			return
+		}
+	}
+}
+
+func (s *Stream) stop(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Err returns the reason the Stream stopped, or nil if it is still running.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Age returns how long ago id was generated, according to the Node's clock.
+func (s *Stream) Age(id ID) time.Duration {
+	return s.node.clock.Now().Sub(s.node.Decompose(id).Time)
+}
+
+// Next receives the next ID from the Stream, skipping any generated more than
+// maxAge ago.  A maxAge of zero accepts IDs of any age.  Once the Stream has
+// stopped and its buffer is empty, Next returns ErrStreamClosed.
+func (s *Stream) Next(maxAge time.Duration) (ID, error) {
+
+	for id := range s.C {
+		// IDs are only as precise as the time unit, so allow for that
+		if maxAge == 0 || s.Age(id) <= maxAge+s.node.unit {
+			return id, nil
+		}
+	}
+
+	return 0, ErrStreamClosed
+}
@@ -0,0 +1,96 @@
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+func TestStream(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := snowflaketest.NewClock(start)
+	node, _ := NewNodeWithConfig(Config{Layout: DefaultLayout(), Node: 1, Clock: clock})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := node.Stream(ctx, 4)
+
+	var last ID
+	for i := 0; i < 10; i++ {
+		id, err := s.Next(0)
+		if err != nil {
+			t.Fatalf("error receiving, %s", err)
+		}
+		if id <= last {
+			t.Fatalf("id %d not greater than %d", id, last)
+		}
+		last = id
+	}
+
+	// everything already buffered is now a second old
+	clock.Advance(time.Second)
+	if age := s.Age(last); age != time.Second {
+		t.Fatalf("age %v != %v", age, time.Second)
+	}
+
+	id, err := s.Next(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("error receiving, %s", err)
+	}
+	if p := node.Decompose(id); !p.Time.Equal(clock.Now()) {
+		t.Fatalf("time %v != %v", p.Time, clock.Now())
+	}
+
+	if s.Err() != nil {
+		t.Fatalf("stream stopped with %v", s.Err())
+	}
+
+	cancel()
+	for {
+		if _, err = s.Next(0); err != nil {
+			break
+		}
+	}
+	if err != ErrStreamClosed {
+		t.Fatalf("error %v != %v", err, ErrStreamClosed)
+	}
+	if s.Err() != context.Canceled {
+		t.Fatalf("error %v != %v", s.Err(), context.Canceled)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+
+	l := DefaultLayout()
+	l.TimeBits = 20
+
+	// the layout has already run out of time bits
+	node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1})
+	s := node.Stream(context.Background(), 4)
+
+	if _, ok := <-s.C; ok {
+		t.Fatalf("received an ID from a stopped stream")
+	}
+	if s.Err() != ErrTimeOverflow {
+		t.Fatalf("error %v != %v", s.Err(), ErrTimeOverflow)
+	}
+}
+
+func BenchmarkStream(b *testing.B) {
+
+	node, _ := NewNode(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := node.Stream(ctx, 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		<-s.C
+	}
+}
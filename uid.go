@@ -0,0 +1,195 @@
+package snowflake
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strconv"
+)
+
+// A UID is an unsigned snowflake ID.  It is used with layouts that set
+// Layout.Unsigned and so may use all 64 bits, including the one that would
+// be the sign bit of an ID.
+type UID uint64
+
+// Uint64 returns an uint64 of the snowflake UID
+func (f UID) Uint64() uint64 {
+	return uint64(f)
+}
+
+// ParseUint64 converts an uint64 into a snowflake UID
+func ParseUint64(id uint64) UID {
+	return UID(id)
+}
+
+// String returns a string of the snowflake UID
+func (f UID) String() string {
+	return strconv.FormatUint(uint64(f), 10)
+}
+
+// ParseUIDString converts a string into a snowflake UID
+func ParseUIDString(id string) (UID, error) {
+	i, err := strconv.ParseUint(id, 10, 64)
+	return UID(i), err
+}
+
+// Base2 returns a string base2 of the snowflake UID
+func (f UID) Base2() string {
+	return strconv.FormatUint(uint64(f), 2)
+}
+
+// ParseUIDBase2 converts a Base2 string into a snowflake UID
+func ParseUIDBase2(id string) (UID, error) {
+	i, err := strconv.ParseUint(id, 2, 64)
+	return UID(i), err
+}
+
+// Base32 uses the z-base-32 character set but encodes and decodes similar
+// to base58, allowing it to create an even smaller result string.
+// NOTE: There are many different base32 implementations so becareful when
+// doing any interoperation.
+func (f UID) Base32() string {
+
+	if f < 32 {
+		return string(encodeBase32Map[f])
+	}
+
+	b := make([]byte, 0, 13)
+	for f >= 32 {
+		b = append(b, encodeBase32Map[f%32])
+		f /= 32
+	}
+	b = append(b, encodeBase32Map[f])
+
+	for x, y := 0, len(b)-1; x < y; x, y = x+1, y-1 {
+		b[x], b[y] = b[y], b[x]
+	}
+
+	return string(b)
+}
+
+// ParseUIDBase32 parses a base32 []byte into a snowflake UID
+// NOTE: There are many different base32 implementations so becareful when
+// doing any interoperation.
+func ParseUIDBase32(b []byte) (UID, error) {
+
+	var id uint64
+
+	for i := range b {
+		if decodeBase32Map[b[i]] == 0xFF {
+			return 0, ErrInvalidBase32
+		}
+		id = id*32 + uint64(decodeBase32Map[b[i]])
+	}
+
+	return UID(id), nil
+}
+
+// Base36 returns a base36 string of the snowflake UID
+func (f UID) Base36() string {
+	return strconv.FormatUint(uint64(f), 36)
+}
+
+// ParseUIDBase36 converts a Base36 string into a snowflake UID
+func ParseUIDBase36(id string) (UID, error) {
+	i, err := strconv.ParseUint(id, 36, 64)
+	return UID(i), err
+}
+
+// Base58 returns a base58 string of the snowflake UID
+func (f UID) Base58() string {
+
+	if f < 58 {
+		return string(encodeBase58Map[f])
+	}
+
+	b := make([]byte, 0, 11)
+	for f >= 58 {
+		b = append(b, encodeBase58Map[f%58])
+		f /= 58
+	}
+	b = append(b, encodeBase58Map[f])
+
+	for x, y := 0, len(b)-1; x < y; x, y = x+1, y-1 {
+		b[x], b[y] = b[y], b[x]
+	}
+
+	return string(b)
+}
+
+// ParseUIDBase58 parses a base58 []byte into a snowflake UID
+func ParseUIDBase58(b []byte) (UID, error) {
+
+	var id uint64
+
+	for i := range b {
+		if decodeBase58Map[b[i]] == 0xFF {
+			return 0, ErrInvalidBase58
+		}
+		id = id*58 + uint64(decodeBase58Map[b[i]])
+	}
+
+	return UID(id), nil
+}
+
+// Base64 returns a base64 string of the snowflake UID
+func (f UID) Base64() string {
+	return base64.StdEncoding.EncodeToString(f.Bytes())
+}
+
+// ParseUIDBase64 converts a base64 string into a snowflake UID
+func ParseUIDBase64(id string) (UID, error) {
+	b, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return 0, err
+	}
+	return ParseUIDBytes(b)
+}
+
+// Bytes returns a byte slice of the snowflake UID
+func (f UID) Bytes() []byte {
+	return []byte(f.String())
+}
+
+// ParseUIDBytes converts a byte slice into a snowflake UID
+func ParseUIDBytes(id []byte) (UID, error) {
+	i, err := strconv.ParseUint(string(id), 10, 64)
+	return UID(i), err
+}
+
+// IntBytes returns an array of bytes of the snowflake UID, encoded as a
+// big endian integer.
+func (f UID) IntBytes() [8]byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(f))
+	return b
+}
+
+// ParseUIDIntBytes converts an array of bytes encoded as big endian integer as
+// a snowflake UID
+func ParseUIDIntBytes(id [8]byte) UID {
+	return UID(binary.BigEndian.Uint64(id[:]))
+}
+
+// MarshalJSON returns a json byte array string of the snowflake UID.
+func (f UID) MarshalJSON() ([]byte, error) {
+	buff := make([]byte, 0, 22)
+	buff = append(buff, '"')
+	buff = strconv.AppendUint(buff, uint64(f), 10)
+	buff = append(buff, '"')
+	return buff, nil
+}
+
+// UnmarshalJSON converts a json byte array of a snowflake UID into an UID type.
+func (f *UID) UnmarshalJSON(b []byte) error {
+	if len(b) < 3 || b[0] != '"' || b[len(b)-1] != '"' {
+		return JSONSyntaxError{b}
+	}
+
+	i, err := strconv.ParseUint(string(b[1:len(b)-1]), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	*f = UID(i)
+	return nil
+}
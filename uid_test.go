@@ -0,0 +1,128 @@
+package snowflake
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// a UID with the top bit set, which does not fit in an ID
+const bigUID UID = 1<<63 | 1116766490855473152
+
+func TestUnsignedLayout(t *testing.T) {
+
+	l := Layout{
+		Epoch:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimeBits: 42,
+		NodeBits: 10,
+		StepBits: 12,
+	}
+	if err := l.Validate(); err != ErrLayoutTooLarge {
+		t.Fatalf("error %v != %v", err, ErrLayoutTooLarge)
+	}
+
+	l.Unsigned = true
+	node, err := NewNodeWithConfig(Config{Layout: l, Node: 1023})
+	if err != nil {
+		t.Fatalf("error creating NewNodeWithConfig, %s", err)
+	}
+
+	id := node.GenerateUID()
+	p := l.DecomposeUID(id)
+	if p.Node != 1023 {
+		t.Fatalf("node %d != 1023", p.Node)
+	}
+
+	// a time that needs the 42nd bit
+	p.Time = l.Epoch.Add(time.Duration(1<<41+5) * time.Millisecond)
+	id, err = l.ComposeUID(p)
+	if err != nil {
+		t.Fatalf("error composing, %s", err)
+	}
+	if id>>63 != 1 {
+		t.Fatalf("top bit of %d is not set", id)
+	}
+	if got := l.DecomposeUID(id); !reflect.DeepEqual(got, p) {
+		t.Fatalf("decomposed %+v != %+v", got, p)
+	}
+}
+
+func TestUIDEncodings(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		encode func(UID) string
+		parse  func(string) (UID, error)
+	}{
+		{"String", UID.String, ParseUIDString},
+		{"Base2", UID.Base2, ParseUIDBase2},
+		{"Base32", UID.Base32, func(s string) (UID, error) { return ParseUIDBase32([]byte(s)) }},
+		{"Base36", UID.Base36, ParseUIDBase36},
+		{"Base58", UID.Base58, func(s string) (UID, error) { return ParseUIDBase58([]byte(s)) }},
+		{"Base64", UID.Base64, ParseUIDBase64},
+		{"Bytes", func(f UID) string { return string(f.Bytes()) }, func(s string) (UID, error) { return ParseUIDBytes([]byte(s)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, id := range []UID{0, 31, 57, 13587, bigUID, 1<<64 - 1} {
+				s := tt.encode(id)
+				got, err := tt.parse(s)
+				if err != nil {
+					t.Fatalf("error parsing %q, %s", s, err)
+				}
+				if got != id {
+					t.Fatalf("parsed %d != %d", got, id)
+				}
+			}
+		})
+	}
+
+	if got := ParseUIDIntBytes(bigUID.IntBytes()); got != bigUID {
+		t.Fatalf("parsed %d != %d", got, bigUID)
+	}
+
+	if got := ParseUint64(bigUID.Uint64()); got != bigUID {
+		t.Fatalf("parsed %d != %d", got, bigUID)
+	}
+
+	if _, err := ParseUIDBase58([]byte("0jgmnx8Js8A")); err != ErrInvalidBase58 {
+		t.Fatalf("error %v != %v", err, ErrInvalidBase58)
+	}
+
+	if _, err := ParseUIDBase32([]byte("b8wjm1zroyyyl")); err != ErrInvalidBase32 {
+		t.Fatalf("error %v != %v", err, ErrInvalidBase32)
+	}
+}
+
+func TestUIDJSON(t *testing.T) {
+
+	b, err := bigUID.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Unexpected error during MarshalJSON")
+	}
+	if string(b) != `"10340138527710248960"` {
+		t.Fatalf("Got %s, expected %s", b, `"10340138527710248960"`)
+	}
+
+	tt := []struct {
+		json        string
+		expectedID  UID
+		expectedErr error
+	}{
+		{`"10340138527710248960"`, bigUID, nil},
+		{`1`, 0, JSONSyntaxError{[]byte(`1`)}},
+		{`"invalid`, 0, JSONSyntaxError{[]byte(`"invalid`)}},
+	}
+
+	for _, tc := range tt {
+		var id UID
+		err := id.UnmarshalJSON([]byte(tc.json))
+		if !reflect.DeepEqual(err, tc.expectedErr) {
+			t.Fatalf("Expected to get error '%s' decoding JSON, but got '%s'", tc.expectedErr, err)
+		}
+
+		if id != tc.expectedID {
+			t.Fatalf("Expected to get UID '%s' decoding JSON, but got '%s'", tc.expectedID, id)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package snowflake
+
+import (
+	"context"
+	"time"
+)
+
+// A WaitStrategy decides how a Node waits for its clock to reach a time, for
+// example when its step number is exhausted and it needs the next time unit.
+// The Node holds its lock while waiting.
+type WaitStrategy interface {
+	// Wait returns nil once clock reads until or later, or ctx.Err() if ctx
+	// is done first.
+	Wait(ctx context.Context, clock Clock, until time.Time) error
+}
+
+// SpinWait keeps reading the clock until it reaches the wanted time.  It
+// reacts the fastest, but keeps a CPU core busy while waiting.  It is the
+// WaitStrategy used when a Config does not set one.
+type SpinWait struct{}
+
+// Wait implements WaitStrategy.
+func (SpinWait) Wait(ctx context.Context, clock Clock, until time.Time) error {
+
+	done := ctx.Done()
+	for clock.Now().Before(until) {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+	}
+
+	return nil
+}
+
+// SleepWait sleeps until the wanted time.  It uses almost no CPU while
+// waiting, but may wake up later than needed as it depends on the operating
+// system's timers.
+type SleepWait struct{}
+
+// Wait implements WaitStrategy.
+func (SleepWait) Wait(ctx context.Context, clock Clock, until time.Time) error {
+
+	for {
+		d := until.Sub(clock.Now())
+		if d <= 0 {
+			return nil
+		}
+
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// HybridWait spins for up to Spin, which covers the short waits that are
+// common when the step number runs out near the end of a time unit, then
+// sleeps for the rest of the wait.
+type HybridWait struct {
+	Spin time.Duration
+}
+
+// Wait implements WaitStrategy.
+func (h HybridWait) Wait(ctx context.Context, clock Clock, until time.Time) error {
+
+	done := ctx.Done()
+	stop := time.Now().Add(h.Spin)
+
+	for clock.Now().Before(until) {
+		if !time.Now().Before(stop) {
+			return SleepWait{}.Wait(ctx, clock, until)
+		}
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+	}
+
+	return nil
+}
+
+// sleep waits for d to pass, or for ctx to be done.
+func sleep(ctx context.Context, d time.Duration) error {
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake/snowflaketest"
+)
+
+var waitStrategies = []struct {
+	name string
+	wait WaitStrategy
+}{
+	{"Spin", SpinWait{}},
+	{"Sleep", SleepWait{}},
+	{"Hybrid", HybridWait{Spin: 100 * time.Microsecond}},
+}
+
+func TestWaitStrategy(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, ws := range waitStrategies {
+		t.Run(ws.name, func(t *testing.T) {
+
+			clock := snowflaketest.NewClock(start)
+			time.AfterFunc(5*time.Millisecond, func() { clock.Advance(time.Millisecond) })
+
+			if err := ws.wait.Wait(context.Background(), clock, start.Add(time.Millisecond)); err != nil {
+				t.Fatalf("error waiting, %s", err)
+			}
+			if now := clock.Now(); now.Before(start.Add(time.Millisecond)) {
+				t.Fatalf("returned at %v", now)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(5*time.Millisecond, cancel)
+			if err := ws.wait.Wait(ctx, clock, start.Add(time.Hour)); err != context.Canceled {
+				t.Fatalf("error %v != %v", err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestNodeWaitStrategy(t *testing.T) {
+
+	// with no step bits every ID has to wait for the next millisecond
+	l := DefaultLayout()
+	l.StepBits = 0
+
+	for _, ws := range waitStrategies {
+		t.Run(ws.name, func(t *testing.T) {
+
+			node, err := NewNodeWithConfig(Config{Layout: l, Node: 1, Wait: ws.wait})
+			if err != nil {
+				t.Fatalf("error creating NewNodeWithConfig, %s", err)
+			}
+
+			ids := node.GenerateN(10)
+			for i := 1; i < len(ids); i++ {
+				if ids[i] <= ids[i-1] {
+					t.Fatalf("id %d not greater than %d", ids[i], ids[i-1])
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWaitStrategy generates IDs with only 16 step numbers per
+// millisecond, so most of the time is spent waiting.  ns/op shows the
+// latency of each strategy, and cpu-ns/op the CPU time it used.
+func BenchmarkWaitStrategy(b *testing.B) {
+
+	l := DefaultLayout()
+	l.NodeBits, l.StepBits = 1, 4
+
+	for _, ws := range waitStrategies {
+		b.Run(ws.name, func(b *testing.B) {
+
+			node, _ := NewNodeWithConfig(Config{Layout: l, Node: 1, Wait: ws.wait})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			cpu := cpuTime()
+			for n := 0; n < b.N; n++ {
+				_ = node.Generate()
+			}
+			if cpu >= 0 {
+				b.ReportMetric(float64(cpuTime()-cpu)/float64(b.N), "cpu-ns/op")
+			}
+		})
+	}
+}